@@ -1,13 +1,21 @@
 package db
 
 import (
+	"database/sql"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/khulnasoft/superkit/db"
+	"github.com/khulnasoft/superkit/kit/metrics"
+	"github.com/khulnasoft/superkit/kit/trace"
 
 	_ "github.com/mattn/go-sqlite3"
 
+	// The gorm dialectors register the pgx and mysql drivers.
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -16,25 +24,59 @@ import (
 // Change this type based on the database package of your likings.
 var dbInstance *gorm.DB
 
+// sqlInstance is the *sql.DB underneath dbInstance.
+var sqlInstance *sql.DB
+
+// driver is the database/sql driver of sqlInstance.
+var driver string
+
 // Get returns the instantiated DB instance.
 func Get() *gorm.DB {
 	return dbInstance
 }
 
+// Driver returns the name of the database/sql driver in use, such as
+// "sqlite3", "pgx" or "mysql".
+func Driver() string {
+	return driver
+}
+
+// SQL returns the *sql.DB underneath the DB instance, for packages such as
+// superkit/jobs that work on database/sql directly.
+func SQL() *sql.DB {
+	return sqlInstance
+}
+
 func init() {
 	// Create a default *sql.DB exposed by the superkit/db package
 	// based on the given configuration.
+	// DATABASE_URL, when set, overrides the other settings.
 	config := db.Config{
 		Driver:   os.Getenv("DB_DRIVER"),
 		Name:     os.Getenv("DB_NAME"),
 		Password: os.Getenv("DB_PASSWORD"),
 		User:     os.Getenv("DB_USER"),
 		Host:     os.Getenv("DB_HOST"),
+		Port:     os.Getenv("DB_PORT"),
+		SSLMode:  os.Getenv("DB_SSLMODE"),
+		DSN:      os.Getenv("DATABASE_URL"),
+
+		MaxOpenConns:    envInt("DB_MAX_OPEN_CONNS"),
+		MaxIdleConns:    envInt("DB_MAX_IDLE_CONNS"),
+		ConnMaxLifetime: envDuration("DB_CONN_MAX_LIFETIME"),
+		ConnMaxIdleTime: envDuration("DB_CONN_MAX_IDLE_TIME"),
+	}
+	var err error
+	driver, _, err = db.DSN(config)
+	if err != nil {
+		log.Fatal(err)
 	}
 	dbinst, err := db.NewSQL(config)
 	if err != nil {
 		log.Fatal(err)
 	}
+	sqlInstance = dbinst
+	metrics.InstrumentDB(metrics.Default, "db", dbinst)
 	// Based on the superkitr create the corresponding DB instance.
 	// By default, the SuperKit boilerplate comes with a pre-configured
 	// ORM called Gorm. https://gorm.io.
@@ -44,17 +86,49 @@ func init() {
 	// - uptrace bun -> https://bun.uptrace.dev
 	// - SQLC -> https://github.com/sqlc-dev/sqlc
 	// - gojet -> https://github.com/go-jet/jet
-	switch config.Driver {
+	//
+	// Queries run in trace spans using the default tracer.
+	switch driver {
 	case db.DriverSqlite3:
 		dbInstance, err = gorm.Open(sqlite.New(sqlite.Config{
-			Conn: dbinst,
+			Conn: trace.WrapDB(nil, dbinst, "sqlite"),
+		}))
+	case db.DriverPostgres, "pgx":
+		dbInstance, err = gorm.Open(postgres.New(postgres.Config{
+			Conn: trace.WrapDB(nil, dbinst, "postgresql"),
 		}))
 	case db.DriverMysql:
-		// ...
+		dbInstance, err = gorm.Open(mysql.New(mysql.Config{
+			Conn: trace.WrapDB(nil, dbinst, "mysql"),
+		}))
 	default:
-		log.Fatal("invalid superkitr:", config.Driver)
+		log.Fatal("unsupported database driver: ", driver)
 	}
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+func envInt(key string) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return n
+}
+
+func envDuration(key string) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", key, err)
+	}
+	return d
+}
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"embed"
+	"path"
+
+	"github.com/khulnasoft/superkit/db"
+	"github.com/khulnasoft/superkit/db/migrate"
+	"github.com/khulnasoft/superkit/kit"
+)
+
+// migrations are compiled into the binary, so it can migrate the database
+// wherever it is deployed.
+//
+//go:embed migrations/*.sql migrations/postgres/*.sql migrations/mysql/*.sql
+var migrations embed.FS
+
+// Migrator applies the migrations in app/db/migrations for sqlite, or in
+// its postgres and mysql subdirectories for those databases. New migrations
+// are created in the matching directory of MIGRATION_DIR.
+func Migrator() *migrate.Migrator {
+	sub := migrationSubdir(driver)
+	return migrate.New(sqlInstance, migrations, migrate.Config{
+		Driver:    driver,
+		Dir:       path.Join("migrations", sub),
+		SourceDir: path.Join(kit.Getenv("MIGRATION_DIR", "app/db/migrations"), sub),
+	})
+}
+
+// migrationSubdir returns the directory holding the migrations of driver,
+// relative to the sqlite migrations.
+func migrationSubdir(driver string) string {
+	switch {
+	case db.IsPostgres(driver):
+		return "postgres"
+	case driver == db.DriverMysql:
+		return "mysql"
+	}
+	return ""
+}
@@ -0,0 +1,17 @@
+-- +goose Up
+create table if not exists event_outbox(
+	id integer primary key,
+	topic text not null,
+	payload text not null,
+	attempts integer not null default 0,
+	last_error text,
+	created_at datetime not null,
+	available_at datetime not null,
+	locked_until datetime,
+	done_at datetime,
+	failed_at datetime
+);
+create index if not exists event_outbox_pending on event_outbox(done_at, failed_at, available_at);
+
+-- +goose Down
+drop table if exists event_outbox;
@@ -0,0 +1,26 @@
+-- +goose Up
+create table if not exists jobs(
+	id integer primary key,
+	queue text not null,
+	kind text not null,
+	payload text not null,
+	priority integer not null default 0,
+	attempts integer not null default 0,
+	max_attempts integer not null,
+	unique_key text unique,
+	last_error text,
+	run_at datetime not null,
+	created_at datetime not null,
+	finished_at datetime,
+	failed_at datetime
+);
+create index if not exists jobs_pending on jobs(queue, finished_at, failed_at, run_at);
+create table if not exists job_leases(
+	job_id integer primary key,
+	worker text not null,
+	expires_at datetime not null
+);
+
+-- +goose Down
+drop table if exists job_leases;
+drop table if exists jobs;
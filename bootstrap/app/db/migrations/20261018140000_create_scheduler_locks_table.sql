@@ -0,0 +1,10 @@
+-- +goose Up
+create table if not exists scheduler_locks(
+	task text primary key,
+	run_at datetime not null,
+	locked_by text,
+	locked_until datetime not null
+);
+
+-- +goose Down
+drop table if exists scheduler_locks;
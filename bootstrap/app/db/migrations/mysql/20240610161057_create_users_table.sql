@@ -0,0 +1,15 @@
+-- +goose Up
+create table if not exists users(
+	id bigint unsigned auto_increment primary key,
+	email varchar(191) unique not null,
+	password_hash varchar(255) not null,
+	first_name varchar(191) not null,
+	last_name varchar(191) not null,
+	email_verified_at datetime(6),
+	created_at datetime(6) not null,
+	updated_at datetime(6) not null,
+	deleted_at datetime(6)
+);
+
+-- +goose Down
+drop table if exists users;
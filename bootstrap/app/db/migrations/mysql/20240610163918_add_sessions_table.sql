@@ -0,0 +1,16 @@
+-- +goose Up
+create table if not exists sessions(
+	id bigint unsigned auto_increment primary key,
+	token varchar(191) not null,
+	user_id bigint unsigned not null,
+	ip_address varchar(191),
+	user_agent text,
+	expires_at datetime(6) not null,
+	created_at datetime(6) not null,
+	updated_at datetime(6) not null,
+	deleted_at datetime(6),
+	foreign key (user_id) references users(id)
+);
+
+-- +goose Down
+drop table if exists sessions;
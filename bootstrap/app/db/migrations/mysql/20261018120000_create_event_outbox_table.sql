@@ -0,0 +1,17 @@
+-- +goose Up
+create table if not exists event_outbox(
+	id bigint auto_increment primary key,
+	topic varchar(191) not null,
+	payload longtext not null,
+	attempts int not null default 0,
+	last_error text,
+	created_at datetime(6) not null,
+	available_at datetime(6) not null,
+	locked_until datetime(6),
+	done_at datetime(6),
+	failed_at datetime(6),
+	index event_outbox_pending (done_at, failed_at, available_at)
+);
+
+-- +goose Down
+drop table if exists event_outbox;
@@ -0,0 +1,22 @@
+-- +goose Up
+create table if not exists jobs(
+	id bigint auto_increment primary key,
+	queue varchar(191) not null,
+	kind varchar(191) not null,
+	payload longtext not null,
+	priority int not null default 0,
+	attempts int not null default 0,
+	max_attempts int not null,
+	unique_key varchar(191) unique,
+	last_error text,
+	run_at datetime(6) not null,
+	created_at datetime(6) not null,
+	finished_at datetime(6),
+	failed_at datetime(6),
+	locked_by varchar(191),
+	locked_until datetime(6),
+	index jobs_pending (queue, finished_at, failed_at, run_at)
+);
+
+-- +goose Down
+drop table if exists jobs;
@@ -0,0 +1,10 @@
+-- +goose Up
+create table if not exists scheduler_locks(
+	task varchar(191) primary key,
+	run_at datetime(6) not null,
+	locked_by varchar(191),
+	locked_until datetime(6) not null
+);
+
+-- +goose Down
+drop table if exists scheduler_locks;
@@ -0,0 +1,15 @@
+-- +goose Up
+create table if not exists users(
+	id bigserial primary key,
+	email text unique not null,
+	password_hash text not null,
+	first_name text not null,
+	last_name text not null,
+	email_verified_at timestamptz,
+	created_at timestamptz not null,
+	updated_at timestamptz not null,
+	deleted_at timestamptz
+);
+
+-- +goose Down
+drop table if exists users;
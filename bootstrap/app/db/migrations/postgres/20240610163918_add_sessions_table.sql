@@ -0,0 +1,15 @@
+-- +goose Up
+create table if not exists sessions(
+	id bigserial primary key,
+	token text not null,
+	user_id bigint not null references users,
+	ip_address text,
+	user_agent text,
+	expires_at timestamptz not null,
+	created_at timestamptz not null,
+	updated_at timestamptz not null,
+	deleted_at timestamptz
+);
+
+-- +goose Down
+drop table if exists sessions;
@@ -0,0 +1,17 @@
+-- +goose Up
+create table if not exists event_outbox(
+	id bigserial primary key,
+	topic text not null,
+	payload text not null,
+	attempts integer not null default 0,
+	last_error text,
+	created_at timestamptz not null,
+	available_at timestamptz not null,
+	locked_until timestamptz,
+	done_at timestamptz,
+	failed_at timestamptz
+);
+create index if not exists event_outbox_pending on event_outbox(available_at) where done_at is null and failed_at is null;
+
+-- +goose Down
+drop table if exists event_outbox;
@@ -0,0 +1,22 @@
+-- +goose Up
+create table if not exists jobs(
+	id bigserial primary key,
+	queue text not null,
+	kind text not null,
+	payload text not null,
+	priority integer not null default 0,
+	attempts integer not null default 0,
+	max_attempts integer not null,
+	unique_key text unique,
+	last_error text,
+	run_at timestamptz not null,
+	created_at timestamptz not null,
+	finished_at timestamptz,
+	failed_at timestamptz,
+	locked_by text,
+	locked_until timestamptz
+);
+create index if not exists jobs_pending on jobs(queue, run_at) where finished_at is null and failed_at is null;
+
+-- +goose Down
+drop table if exists jobs;
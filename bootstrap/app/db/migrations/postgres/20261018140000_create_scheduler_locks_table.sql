@@ -0,0 +1,10 @@
+-- +goose Up
+create table if not exists scheduler_locks(
+	task text primary key,
+	run_at timestamptz not null,
+	locked_by text,
+	locked_until timestamptz not null
+);
+
+-- +goose Down
+drop table if exists scheduler_locks;
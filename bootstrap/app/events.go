@@ -1,10 +1,20 @@
 package app
 
 import (
+	"context"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"AABBCCDD/app/db"
 	"AABBCCDD/app/events"
 	"AABBCCDD/plugins/auth"
 
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/khulnasoft/superkit/event"
+	"github.com/khulnasoft/superkit/kit"
+	"github.com/khulnasoft/superkit/kit/trace"
 )
 
 // Events are functions that are handled in separate goroutines.
@@ -16,6 +26,50 @@ import (
 
 // Register your events here.
 func RegisterEvents() {
-	event.Subscribe(auth.UserSignupEvent, events.OnUserSignup)
-	event.Subscribe(auth.ResendVerificationEvent, events.OnResendVerificationToken)
+	// Events emitted with EmitTx are written in the placeholder style of
+	// the database in use.
+	event.SetOutboxDriver(db.Driver())
+	// Block emitters for a while rather than dropping events such as
+	// verification emails when handlers fall behind.
+	event.Configure(event.Config{
+		BufferSize:   256,
+		Overflow:     event.Block,
+		BlockTimeout: 5 * time.Second,
+	})
+	// Handlers of events emitted with a request context see its request ID
+	// and authenticated user, but keep running after the response is sent.
+	event.PropagateContext(chimiddleware.RequestIDKey, kit.AuthKey{})
+	// Every handler recovers from panics, is logged and runs in a trace span.
+	event.Use(
+		event.Recover(),
+		event.Logger(nil),
+		trace.EventMiddleware(trace.Default()),
+	)
+	auth.UserSignup.Subscribe(events.OnUserSignup)
+	auth.ResendVerification.Subscribe(events.OnResendVerificationToken)
+	auth.ProfileReminder.Subscribe(events.OnProfileReminder)
+
+	// Share events with the other instances of the app when running more
+	// than one behind a load balancer. Any instance that can reach
+	// EVENT_PEER_LISTEN can emit events here, so the instances share
+	// EVENT_PEER_SECRET to authenticate each other; listening on anything
+	// but a loopback address fails without it. The secret does not encrypt
+	// the events: keep the peers on a private network or configure TLS.
+	listen, peers := os.Getenv("EVENT_PEER_LISTEN"), os.Getenv("EVENT_PEERS")
+	if listen != "" || peers != "" {
+		t := event.NewPeerTransport(event.PeerConfig{
+			Listen: listen,
+			Peers:  strings.FieldsFunc(peers, func(r rune) bool { return r == ',' || r == ' ' }),
+			Secret: os.Getenv("EVENT_PEER_SECRET"),
+		})
+		if err := event.Connect(t); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// RunOutbox delivers events stored in the outbox, such as the signup
+// verification email, until ctx is cancelled.
+func RunOutbox(ctx context.Context) error {
+	return event.NewOutbox(db.Get().ConnPool, event.OutboxConfig{Driver: db.Driver()}).Run(ctx)
 }
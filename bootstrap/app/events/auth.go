@@ -3,25 +3,28 @@ package events
 import (
 	"AABBCCDD/plugins/auth"
 	"context"
-	"encoding/json"
 	"fmt"
+	"log/slog"
 )
 
 // Event handlers
-func OnUserSignup(ctx context.Context, event any) {
-	userWithToken, ok := event.(auth.UserWithVerificationToken)
-	if !ok {
-		return
-	}
-	b, _ := json.MarshalIndent(userWithToken, "   ", "    ")
-	fmt.Println(string(b))
+func OnUserSignup(ctx context.Context, user auth.UserEvent) {
+	sendVerificationEmail(user)
+}
+
+func OnResendVerificationToken(ctx context.Context, user auth.UserEvent) {
+	sendVerificationEmail(user)
+}
+
+func OnProfileReminder(ctx context.Context, user auth.UserEvent) {
+	fmt.Printf("reminding %s to complete their profile\n", user.Email)
 }
 
-func OnResendVerificationToken(ctx context.Context, event any) {
-	userWithToken, ok := event.(auth.UserWithVerificationToken)
-	if !ok {
+func sendVerificationEmail(user auth.UserEvent) {
+	token, err := auth.NewVerificationToken(user.UserID)
+	if err != nil {
+		slog.Error("creating verification token failed", "user", user.UserID, "err", err)
 		return
 	}
-	b, _ := json.MarshalIndent(userWithToken, "   ", "    ")
-	fmt.Println(string(b))
+	fmt.Printf("sending verification email to %s: /email/verify?token=%s\n", user.Email, token)
 }
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+	"fmt"
+
+	"AABBCCDD/app/db"
+	"AABBCCDD/plugins/auth"
+
+	"github.com/khulnasoft/superkit/jobs"
+)
+
+// Jobs is the durable background job queue. Unlike events, jobs survive
+// restarts and are retried when they fail, which makes them the right fit
+// for work that must happen eventually:
+// - sending email
+// - exports
+// - webhooks
+var Jobs *jobs.Client
+
+// WelcomeEmail sends the welcome email to a user. Enqueue it with
+//
+//	app.Jobs.Enqueue(ctx, app.WelcomeEmail{UserID: user.ID}, jobs.EnqueueOptions{})
+type WelcomeEmail struct {
+	UserID uint
+}
+
+func (WelcomeEmail) Kind() string { return "welcome_email" }
+
+// Register your jobs here.
+func RegisterJobs() {
+	Jobs = jobs.New(db.SQL(), jobs.Config{
+		Dialect: jobs.DialectFor(db.Driver()),
+	})
+	jobs.Register(Jobs, func(ctx context.Context, job WelcomeEmail) error {
+		var user auth.User
+		if err := db.Get().WithContext(ctx).First(&user, job.UserID).Error; err != nil {
+			return err
+		}
+		fmt.Printf("welcome %s\n", user.Email)
+		return nil
+	})
+}
+
+// RunJobs works the job queue until ctx is cancelled.
+func RunJobs(ctx context.Context) error {
+	return Jobs.Run(ctx)
+}
@@ -4,7 +4,10 @@ import (
 	"AABBCCDD/app/handlers"
 	"AABBCCDD/app/views/errors"
 	"AABBCCDD/plugins/auth"
+	stderrors "errors"
 	"net/http"
+	"os"
+	"strings"
 	"time"
 
 	"log/slog"
@@ -12,45 +15,95 @@ import (
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 
+	superkitdb "github.com/khulnasoft/superkit/db"
 	"github.com/khulnasoft/superkit/kit"
+	"github.com/khulnasoft/superkit/kit/debug"
+	"github.com/khulnasoft/superkit/kit/metrics"
 	"github.com/khulnasoft/superkit/kit/middleware"
+	"github.com/khulnasoft/superkit/kit/trace"
 )
 
+const (
+	// maxBodySize caps request bodies for every route.
+	maxBodySize = 1 << 20 // 1 MiB
+	// requestTimeout is the deadline for rendering pages.
+	requestTimeout = 10 * time.Second
+)
+
+// PageCache caches rendered public pages. Anonymous and logged in visitors
+// get separate entries. Invalidate entries by tag from handlers or event
+// subscribers, e.g. app.PageCache.Invalidate(ctx, "landing").
+var PageCache = middleware.NewPageCache(middleware.PageCacheConfig{
+	TTL:      10 * time.Minute,
+	VaryAuth: true,
+	Tags: func(r *http.Request) []string {
+		return []string{r.URL.Path}
+	},
+})
+
 // InitializeMiddleware wires up global middleware for the application router.
 // Enhancements:
-// - Added RequestID and RealIP middleware for better observability.
+// - Added RequestID and WithRealIP middleware for better observability.
 // - Replaced the single WithRequest middleware with WithRequestAndResponseHeaders
 //   so handlers can accumulate response headers in context.
-// - Added a final middleware that applies accumulated response headers and logs timing.
+// - Added a final middleware that applies accumulated response headers.
+// - Replaced chi's Logger with kit's AccessLog, which records status, size,
+//   route, request ID and user ID.
 func InitializeMiddleware(router *chi.Mux) {
 	// Standard Chi middleware
 	router.Use(chimiddleware.RequestID)
-	router.Use(chimiddleware.RealIP)
-	router.Use(chimiddleware.Logger)
+	// Client IPs from X-Forwarded-For/X-Real-IP are only believed when the
+	// request comes from one of TRUSTED_PROXIES, so the maintenance
+	// allowlist cannot be bypassed with a forged header.
+	router.Use(middleware.WithRealIP(middleware.RealIPConfig{
+		TrustedProxies: strings.FieldsFunc(kit.Getenv("TRUSTED_PROXIES", ""), func(r rune) bool {
+			return r == ',' || r == ' '
+		}),
+	}))
+	router.Use(middleware.AccessLog(middleware.AccessLogConfig{
+		Output:    os.Stdout,
+		Format:    middleware.ParseAccessLogFormat(kit.Getenv("ACCESS_LOG_FORMAT", "combined")),
+		Exclude:   []string{"/healthz", "/metrics", "/public/*"},
+		RouteFunc: routePattern,
+		RequestIDFunc: func(r *http.Request) string {
+			return chimiddleware.GetReqID(r.Context())
+		},
+	}))
 	router.Use(chimiddleware.Recoverer)
+	router.Use(metrics.Middleware(metrics.Default, metrics.MiddlewareConfig{
+		RouteFunc: routePattern,
+	}))
+	router.Use(trace.Middleware(trace.Default(), trace.MiddlewareConfig{
+		RouteFunc: routePattern,
+	}))
+
+	// Maintenance mode, toggled with `make down` / `make up`.
+	router.Use(middleware.WithMaintenance(middleware.MaintenanceConfig{
+		Switch:      middleware.MaintenanceFile(kit.Getenv("MAINTENANCE_FILE", "tmp/maintenance.json")),
+		Page:        errors.Maintenance(),
+		BypassPaths: []string{"/healthz", "/metrics", "/public/*"},
+	}))
 
 	// App-level middleware from kit
 	router.Use(middleware.WithRequestAndResponseHeaders)
+	router.Use(middleware.WithMaxBodySize(maxBodySize))
 
-	// Final middleware: apply any headers accumulated in context and log request timing.
+	// Final middleware: apply any headers accumulated in context.
 	router.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
 			// Serve the request
 			next.ServeHTTP(w, r)
 			// Apply headers that handlers might have added to the context
 			middleware.ApplyResponseHeaders(w, r.Context())
-
-			// Light-weight structured debug info
-			slog.Debug("request complete",
-				"method", r.Method,
-				"path", r.URL.Path,
-				"remote", r.RemoteAddr,
-				"duration", time.Since(start))
 		})
 	})
 }
 
+// routePattern returns the chi route pattern matched by r.
+func routePattern(r *http.Request) string {
+	return chi.RouteContext(r.Context()).RoutePattern()
+}
+
 // InitializeRoutes registers all application routes and authentication wiring.
 //
 // Improvements:
@@ -61,6 +114,16 @@ func InitializeRoutes(router *chi.Mux) {
 	// Initialize auth plugin routes (login, logout, etc).
 	auth.InitializeRoutes(router)
 
+	// Prometheus metrics, protected by METRICS_TOKEN.
+	metrics.InstrumentEvents(metrics.Default)
+	router.Handle("/metrics", metrics.Handler(metrics.Default, kit.Getenv("METRICS_TOKEN", "")))
+
+	// Event bus statistics: a dashboard in development, JSON protected by
+	// DEBUG_TOKEN elsewhere.
+	router.Get("/debug/events", kit.Handler(debug.Events(debug.EventsConfig{
+		Token: kit.Getenv("DEBUG_TOKEN", ""),
+	})))
+
 	authConfig := kit.AuthenticationConfig{
 		AuthFunc:    auth.AuthenticateUser,
 		RedirectURL: "/login",
@@ -69,11 +132,22 @@ func InitializeRoutes(router *chi.Mux) {
 	// Public / optionally-authenticated routes (auth present if available)
 	router.Group(func(r chi.Router) {
 		r.Use(kit.WithAuthentication(authConfig, false)) // non-strict: auth may be present
-		r.Get("/", kit.Handler(handlers.HandleLandingIndex))
+		r.With(
+			PageCache.Middleware,
+			middleware.WithTimeout(middleware.TimeoutConfig{
+				Timeout:   requestTimeout,
+				ErrorPage: errors.Error503(),
+			}),
+		).Get("/", kit.Handler(handlers.HandleLandingIndex))
 		// health check
 		r.Get("/healthz", kit.Handler(func(k *kit.Kit) error {
-			// lightweight health endpoint used by load balancers and runtime checks
-			return k.JSON(http.StatusOK, map[string]string{"status": "ok"})
+			// lightweight health endpoint used by load balancers and runtime
+			// checks, reporting database latency and pool statistics
+			health, err := superkitdb.HealthCheck(k.Request.Context())
+			if err != nil {
+				return k.JSON(http.StatusServiceUnavailable, health)
+			}
+			return k.JSON(http.StatusOK, health)
 		}))
 	})
 
@@ -94,6 +168,14 @@ func NotFoundHandler(k *kit.Kit) error {
 
 // ErrorHandler is the centralized error handler used by kit.Handler wrapper.
 func ErrorHandler(k *kit.Kit, err error) {
+	// Errors carrying their own status (for example a 413 from kit.BindJSON)
+	// are client errors and are reported as such.
+	var statusErr *kit.StatusError
+	if stderrors.As(err, &statusErr) {
+		_ = k.Text(statusErr.Code, http.StatusText(statusErr.Code))
+		return
+	}
+
 	// Log with context for easier debugging in observability systems.
 	slog.Error("internal server error",
 		"err", err.Error(),
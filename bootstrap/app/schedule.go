@@ -0,0 +1,46 @@
+package app
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"time"
+
+	"AABBCCDD/app/db"
+	"AABBCCDD/plugins/auth"
+
+	"github.com/khulnasoft/superkit/scheduler"
+)
+
+// Scheduler runs recurring tasks such as housekeeping and digest emails.
+// Its lock makes every run happen on a single instance when the app runs
+// on several.
+var Scheduler *scheduler.Scheduler
+
+// Register your recurring tasks here.
+func RegisterSchedule() {
+	Scheduler = scheduler.New(scheduler.Config{
+		Locker: scheduler.NewSQLLocker(db.SQL(), db.Driver()),
+	})
+	err := Scheduler.Add("purge-expired-sessions", "@hourly", purgeExpiredSessions, scheduler.TaskOptions{})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// RunScheduler runs the recurring tasks until ctx is cancelled.
+func RunScheduler(ctx context.Context) error {
+	return Scheduler.Run(ctx)
+}
+
+// purgeExpiredSessions deletes the sessions that expired.
+func purgeExpiredSessions(ctx context.Context) error {
+	res := db.Get().WithContext(ctx).Unscoped().
+		Where("expires_at < ?", time.Now()).
+		Delete(&auth.Session{})
+	if res.Error != nil {
+		return res.Error
+	}
+	slog.Info("purged expired sessions", "count", res.RowsAffected)
+	return nil
+}
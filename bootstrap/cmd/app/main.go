@@ -6,21 +6,38 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
-	"time"
 
+	"AABBCCDD/app/db"
 	"app"
 	"public"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/khulnasoft/superkit/kit"
+	"github.com/khulnasoft/superkit/kit/trace"
 )
 
 func main() {
 	// Initialize kit (loads env, validates secret, configures session store).
 	kit.Setup()
 
+	// `app migrate <command>` migrates the database with the migrations
+	// compiled into the binary, see migrate.Usage.
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := db.Migrator().Run(context.Background(), os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if kit.Getenv("DB_MIGRATE_ON_BOOT", "false") == "true" {
+		if err := db.Migrator().Up(context.Background()); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Configure tracing before any middleware or event handler uses it.
+	tracer := newTracer()
+	trace.SetDefault(tracer)
+
 	// Create router and let app initialize middleware and routes.
 	router := chi.NewMux()
 	app.InitializeMiddleware(router)
@@ -35,51 +52,59 @@ func main() {
 	// Use the application's error handler globally.
 	kit.UseErrorHandler(app.ErrorHandler)
 
-	// Register not-found handler and app routes, and application events.
+	// Register not-found handler and app routes, application events, jobs
+	// and recurring tasks.
 	router.HandleFunc("/*", kit.Handler(app.NotFoundHandler))
 	app.InitializeRoutes(router)
 	app.RegisterEvents()
-
-	// Listen address with sensible default.
-	listenAddr := os.Getenv("HTTP_LISTEN_ADDR")
-	if listenAddr == "" {
-		listenAddr = ":8080"
-	}
+	app.RegisterJobs()
+	app.RegisterSchedule()
 
 	// Human-friendly URL for logs (in development, Templ proxy is expected).
 	url := "http://localhost:7331"
 	if kit.IsProduction() {
-		url = fmt.Sprintf("http://localhost%s", listenAddr)
+		url = fmt.Sprintf("http://localhost%s", kit.Getenv("HTTP_LISTEN_ADDR", ":8080"))
 	}
 
 	log.Printf("application running in %s at %s\n", kit.Env(), url)
 
-	// Create server to allow graceful shutdown.
-	srv := &http.Server{
-		Addr:    listenAddr,
+	// Serve until interrupted, then finish in-flight requests, stop the
+	// outbox, the job workers and the scheduler and flush the tracer.
+	err := kit.Serve(context.Background(), kit.ServeConfig{
 		Handler: router,
+		Background: []func(context.Context) error{
+			// Deliver events written to the outbox.
+			app.RunOutbox,
+			app.RunJobs,
+			app.RunScheduler,
+		},
+		OnShutdown: []func(context.Context) error{tracer.Shutdown},
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	// Start server in a goroutine.
-	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("server error: %v", err)
+	log.Printf("server stopped")
+}
+
+// newTracer creates the application tracer based on TRACE_EXPORTER:
+// "file" appends spans to TRACE_FILE, "otlp" sends them to the collector at
+// OTEL_EXPORTER_OTLP_ENDPOINT. Without an exporter spans are only propagated.
+func newTracer() *trace.Tracer {
+	cfg := trace.Config{ServiceName: kit.Getenv("TRACE_SERVICE_NAME", "superkit")}
+	switch os.Getenv("TRACE_EXPORTER") {
+	case "file":
+		exp, err := trace.NewFileExporter(kit.Getenv("TRACE_FILE", "traces.jsonl"))
+		if err != nil {
+			log.Fatalf("trace exporter: %v", err)
 		}
-	}()
-
-	// Wait for interrupt signal to gracefully shutdown the server with a timeout.
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
-	<-quit
-	log.Printf("shutdown signal received, shutting down...")
-
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-	if err := srv.Shutdown(ctx); err != nil {
-		log.Fatalf("server forced to shutdown: %v", err)
+		cfg.Exporter = exp
+	case "otlp":
+		cfg.Exporter = trace.NewOTLPExporter(trace.OTLPConfig{
+			Endpoint: kit.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT", "http://localhost:4318"),
+		})
 	}
-
-	log.Printf("server stopped")
+	return trace.NewTracer(cfg)
 }
 
 func staticDev() http.Handler {
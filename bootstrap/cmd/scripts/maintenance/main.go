@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/khulnasoft/superkit/kit"
+	"github.com/khulnasoft/superkit/kit/middleware"
+)
+
+// Toggles maintenance mode of a running application without restarting it.
+//
+//	go run cmd/scripts/maintenance/main.go down -secret s3cret -retry 120 -allow 10.0.0.0/8
+//	go run cmd/scripts/maintenance/main.go up
+func main() {
+	_ = godotenv.Load()
+	flagFile := middleware.MaintenanceFile(kit.Getenv("MAINTENANCE_FILE", "tmp/maintenance.json"))
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "down":
+		fs := flag.NewFlagSet("down", flag.ExitOnError)
+		secret := fs.String("secret", "", "visiting /<secret> sets a cookie that bypasses maintenance mode")
+		retry := fs.Int("retry", 60, "seconds sent in the Retry-After header")
+		allow := fs.String("allow", "", "comma separated IPs or CIDR ranges that bypass maintenance mode")
+		fs.Parse(os.Args[2:])
+
+		state := middleware.MaintenanceState{Secret: *secret, RetryAfter: *retry}
+		if *allow != "" {
+			state.AllowIPs = strings.Split(*allow, ",")
+		}
+		if err := flagFile.Enable(state); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("application is now in maintenance mode")
+	case "up":
+		if err := flagFile.Disable(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("application is now live")
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: maintenance down [-secret s] [-retry seconds] [-allow ips] | up")
+	os.Exit(2)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/khulnasoft/superkit/kit"
+	"github.com/khulnasoft/superkit/kit/middleware"
 	v "github.com/khulnasoft/superkit/validate"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -36,6 +37,9 @@ func HandleLoginIndex(kit *kit.Kit) error {
 func HandleLoginCreate(kit *kit.Kit) error {
 	var values LoginFormValues
 	errors, ok := v.Request(kit.Request, &values, authSchema)
+	if errors.TooLarge() {
+		return kit.Text(http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge))
+	}
 	if !ok {
 		return kit.Render(LoginForm(values, errors))
 	}
@@ -164,6 +168,7 @@ func AuthenticateUser(kit *kit.Kit) (kit.Auth, error) {
 		return auth, nil
 	}
 
+	middleware.SetAccessLogUser(kit.Request.Context(), strconv.FormatUint(uint64(session.User.ID), 10))
 	return Auth{
 		LoggedIn: true,
 		UserID:   session.User.ID,
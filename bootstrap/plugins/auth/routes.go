@@ -1,8 +1,11 @@
 package auth
 
 import (
+	"time"
+
 	"github.com/go-chi/chi/v5"
 	"github.com/khulnasoft/superkit/kit"
+	"github.com/khulnasoft/superkit/kit/middleware"
 )
 
 func InitializeRoutes(router chi.Router) {
@@ -21,7 +24,10 @@ func InitializeRoutes(router chi.Router) {
 		auth.Delete("/logout", kit.Handler(HandleLoginDelete))
 
 		auth.Get("/signup", kit.Handler(HandleSignupIndex))
-		auth.Post("/signup", kit.Handler(HandleSignupCreate))
+		auth.With(middleware.WithIdempotency(middleware.IdempotencyConfig{
+			TTL:  time.Hour,
+			Wait: 5 * time.Second,
+		})).Post("/signup", kit.Handler(HandleSignupCreate))
 
 	})
 
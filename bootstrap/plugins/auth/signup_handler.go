@@ -3,15 +3,16 @@ package auth
 import (
 	"AABBCCDD/app/db"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/khulnasoft/superkit/event"
 	"github.com/khulnasoft/superkit/kit"
 	v "github.com/khulnasoft/superkit/validate"
+	"gorm.io/gorm"
 )
 
 var signupSchema = v.Schema{
@@ -33,6 +34,9 @@ func HandleSignupIndex(kit *kit.Kit) error {
 func HandleSignupCreate(kit *kit.Kit) error {
 	var values SignupFormValues
 	errors, ok := v.Request(kit.Request, &values, signupSchema)
+	if errors.TooLarge() {
+		return kit.Text(http.StatusRequestEntityTooLarge, http.StatusText(http.StatusRequestEntityTooLarge))
+	}
 	if !ok {
 		return kit.Render(SignupForm(values, errors))
 	}
@@ -40,18 +44,25 @@ func HandleSignupCreate(kit *kit.Kit) error {
 		errors.Add("passwordConfirm", "passwords do not match")
 		return kit.Render(SignupForm(values, errors))
 	}
-	user, err := createUserFromFormValues(values)
-	if err != nil {
+	var user User
+	err := db.Get().Transaction(func(tx *gorm.DB) error {
+		var err error
+		user, err = createUserFromFormValues(tx, values)
+		if err != nil {
+			return err
+		}
+		// The events are committed together with the user, so the verification
+		// email and the reminder are not lost if the process stops.
+		ctx := kit.Request.Context()
+		if err := UserSignup.EmitTx(ctx, tx.Statement.ConnPool, newUserEvent(user)); err != nil {
+			return err
+		}
+		_, err = ProfileReminder.EmitTxAt(ctx, tx.Statement.ConnPool, time.Now().Add(ProfileReminderDelay), newUserEvent(user))
 		return err
-	}
-	token, err := createVerificationToken(user.ID)
+	})
 	if err != nil {
 		return err
 	}
-	event.Emit(UserSignupEvent, UserWithVerificationToken{
-		Token: token,
-		User:  user,
-	})
 	return kit.Render(ConfirmEmail(user))
 }
 
@@ -71,22 +82,20 @@ func HandleResendVerificationCode(kit *kit.Kit) error {
 		return kit.Text(http.StatusOK, "Email already verified!")
 	}
 
-	token, err := createVerificationToken(uint(id))
+	err = ResendVerification.EmitTx(kit.Request.Context(), db.Get().ConnPool, newUserEvent(user))
 	if err != nil {
+		slog.Error("failed to queue resend verification event", "err", err)
 		return kit.Text(http.StatusOK, "An unexpected error occured")
 	}
 
-	event.Emit(ResendVerificationEvent, UserWithVerificationToken{
-		User:  user,
-		Token: token,
-	})
-
 	msg := fmt.Sprintf("A new verification token has been sent to %s", user.Email)
 
 	return kit.Text(http.StatusOK, msg)
 }
 
-func createVerificationToken(userID uint) (string, error) {
+// NewVerificationToken returns a token verifying the email of the user. It
+// is created when the email is sent rather than stored with the event.
+func NewVerificationToken(userID uint) (string, error) {
 	expiryStr := kit.Getenv("SUPERKIT_AUTH_EMAIL_VERIFICATION_EXPIRY_IN_HOURS", "1")
 	expiry, err := strconv.Atoi(expiryStr)
 	if err != nil {
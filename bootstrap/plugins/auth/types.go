@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"time"
 
+	"github.com/khulnasoft/superkit/event"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -13,13 +14,32 @@ import (
 const (
 	UserSignupEvent         = "auth.signup"
 	ResendVerificationEvent = "auth.resend.verification"
+	ProfileReminderEvent    = "auth.profile.reminder"
 )
 
-// UserWithVerificationToken is a struct that will be sent over the
-// auth.signup event. It holds the User struct and the Verification token string.
-type UserWithVerificationToken struct {
-	User  User
-	Token string
+// Typed event topics. Emit and subscribe through these rather than the
+// event name constants so payload types are checked at compile time.
+var (
+	UserSignup         = event.NewTopic[UserEvent](UserSignupEvent)
+	ResendVerification = event.NewTopic[UserEvent](ResendVerificationEvent)
+	ProfileReminder    = event.NewTopic[UserEvent](ProfileReminderEvent)
+)
+
+// ProfileReminderDelay is how long after signup users are reminded to
+// complete their profile.
+const ProfileReminderDelay = 24 * time.Hour
+
+// UserEvent is sent over the auth events. Events are stored in the outbox
+// and may be shared with peers, so it identifies the user without carrying
+// the password hash or a verification token; handlers sending the
+// verification email create the token with NewVerificationToken.
+type UserEvent struct {
+	UserID uint
+	Email  string
+}
+
+func newUserEvent(user User) UserEvent {
+	return UserEvent{UserID: user.ID, Email: user.Email}
 }
 
 type Auth struct {
@@ -44,7 +64,7 @@ type User struct {
 	UpdatedAt       time.Time
 }
 
-func createUserFromFormValues(values SignupFormValues) (User, error) {
+func createUserFromFormValues(tx *gorm.DB, values SignupFormValues) (User, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(values.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return User{}, err
@@ -55,7 +75,7 @@ func createUserFromFormValues(values SignupFormValues) (User, error) {
 		LastName:     values.LastName,
 		PasswordHash: string(hash),
 	}
-	result := db.Get().Create(&user)
+	result := tx.Create(&user)
 	return user, result.Error
 }
 
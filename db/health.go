@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// defaultDB is the database last opened by NewSQL.
+var defaultDB atomic.Pointer[sql.DB]
+
+// Health is the result of a health check, ready to be served as JSON.
+type Health struct {
+	// Status is "ok" or "unavailable".
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	LatencyMS float64   `json:"latency_ms"`
+	Pool      PoolStats `json:"pool"`
+}
+
+// PoolStats are the connection pool statistics of a health check.
+type PoolStats struct {
+	MaxOpen        int     `json:"max_open"`
+	Open           int     `json:"open"`
+	InUse          int     `json:"in_use"`
+	Idle           int     `json:"idle"`
+	WaitCount      int64   `json:"wait_count"`
+	WaitDurationMS float64 `json:"wait_duration_ms"`
+}
+
+// HealthCheck pings the database opened by NewSQL. The error is non-nil if
+// the database did not answer; Health describes the outcome either way:
+//
+//	router.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
+//		h, err := db.HealthCheck(r.Context())
+//		if err != nil {
+//			w.WriteHeader(http.StatusServiceUnavailable)
+//		}
+//		json.NewEncoder(w).Encode(h)
+//	})
+func HealthCheck(ctx context.Context) (Health, error) {
+	db := defaultDB.Load()
+	if db == nil {
+		err := errors.New("db: no database opened")
+		return Health{Status: "unavailable", Error: err.Error()}, err
+	}
+	return Check(ctx, db)
+}
+
+// Check is like HealthCheck for the given database.
+func Check(ctx context.Context, db *sql.DB) (Health, error) {
+	start := time.Now()
+	err := db.PingContext(ctx)
+	s := db.Stats()
+	h := Health{
+		Status:    "ok",
+		LatencyMS: float64(time.Since(start)) / float64(time.Millisecond),
+		Pool: PoolStats{
+			MaxOpen:        s.MaxOpenConnections,
+			Open:           s.OpenConnections,
+			InUse:          s.InUse,
+			Idle:           s.Idle,
+			WaitCount:      s.WaitCount,
+			WaitDurationMS: float64(s.WaitDuration) / float64(time.Millisecond),
+		},
+	}
+	if err != nil {
+		h.Status = "unavailable"
+		h.Error = err.Error()
+	}
+	return h, err
+}
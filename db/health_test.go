@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyConnector hands out connections whose pings fail until failures
+// reaches zero.
+type flakyConnector struct {
+	failures atomic.Int32
+	pings    atomic.Int32
+}
+
+func (c *flakyConnector) Connect(context.Context) (driver.Conn, error) {
+	return flakyConn{c}, nil
+}
+
+func (c *flakyConnector) Driver() driver.Driver { return nil }
+
+type flakyConn struct{ c *flakyConnector }
+
+func (flakyConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (flakyConn) Close() error                        { return nil }
+func (flakyConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (f flakyConn) Ping(context.Context) error {
+	f.c.pings.Add(1)
+	if f.c.failures.Add(-1) >= 0 {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func TestPingRetries(t *testing.T) {
+	c := &flakyConnector{}
+	c.failures.Store(2)
+	db := sql.OpenDB(c)
+	defer db.Close()
+
+	if err := ping(context.Background(), db); err != nil {
+		t.Fatal(err)
+	}
+	if n := c.pings.Load(); n != 3 {
+		t.Fatalf("expected 3 pings got %d", n)
+	}
+
+	c.failures.Store(1000)
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+	if err := ping(ctx, db); err == nil {
+		t.Fatal("expected error for unreachable database")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	c := &flakyConnector{}
+	db := sql.OpenDB(c)
+	defer db.Close()
+	db.SetMaxOpenConns(4)
+
+	h, err := Check(context.Background(), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Status != "ok" || h.Pool.MaxOpen != 4 || h.Pool.Open != 1 {
+		t.Fatalf("unexpected health %+v", h)
+	}
+
+	c.failures.Store(1)
+	h, err = Check(context.Background(), db)
+	if err == nil || h.Status != "unavailable" || h.Error != "connection refused" {
+		t.Fatalf("expected unavailable, got %+v %v", h, err)
+	}
+}
@@ -0,0 +1,140 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Usage describes the commands of Run.
+const Usage = `commands:
+  up                 apply all pending migrations
+  up-to VERSION      apply the pending migrations up to VERSION
+  down               roll back the latest migration
+  down-to VERSION    roll back the migrations newer than VERSION
+  redo               roll back the latest migration and apply it again
+  reset              roll back all migrations
+  status             list the migrations and whether they are applied
+  create NAME        write a new migration to the source directory`
+
+// Run runs the migration command given by args, so the application binary
+// can migrate its database without other tools:
+//
+//	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+//		err := m.Run(ctx, os.Args[2:], os.Stdout)
+//	}
+//
+// See Usage for the commands.
+func (m *Migrator) Run(ctx context.Context, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("migrate: missing command\n%s", Usage)
+	}
+	cmd, args := args[0], args[1:]
+	version := func() (int64, error) {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("migrate: %s expects a version", cmd)
+		}
+		v, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("migrate: invalid version %q", args[0])
+		}
+		return v, nil
+	}
+	switch cmd {
+	case "up":
+		return m.Up(ctx)
+	case "up-to":
+		v, err := version()
+		if err != nil {
+			return err
+		}
+		return m.UpTo(ctx, v)
+	case "down":
+		return m.Down(ctx)
+	case "down-to":
+		v, err := version()
+		if err != nil {
+			return err
+		}
+		return m.DownTo(ctx, v)
+	case "redo":
+		return m.Redo(ctx)
+	case "reset":
+		return m.DownTo(ctx, 0)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printStatus(w, statuses)
+	case "create":
+		if len(args) != 1 {
+			return fmt.Errorf("migrate: create expects a name")
+		}
+		path, err := Create(m.cfg.SourceDir, args[0], time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "created %s\n", path)
+		return nil
+	}
+	return fmt.Errorf("migrate: unknown command %q\n%s", cmd, Usage)
+}
+
+func printStatus(w io.Writer, statuses []Status) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tSTATE\tMIGRATION")
+	for _, s := range statuses {
+		state, name := "pending", s.Name
+		switch {
+		case s.Missing:
+			state, name = "applied", "(no migration file)"
+		case s.Applied:
+			state = "applied"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, state, name)
+	}
+	return tw.Flush()
+}
+
+const template = `-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+`
+
+var nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Create writes a new migration named name to dir and returns its path.
+// Like goose, the version is the timestamp now in UTC.
+func Create(dir, name string, now time.Time) (string, error) {
+	name = strings.Trim(nonWord.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return "", fmt.Errorf("migrate: invalid migration name")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("migrate: %w", err)
+	}
+	path := filepath.Join(dir, now.UTC().Format("20060102150405")+"_"+name+".sql")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("migrate: %w", err)
+	}
+	if _, err := f.WriteString(template); err != nil {
+		f.Close()
+		return "", fmt.Errorf("migrate: %w", err)
+	}
+	return path, f.Close()
+}
@@ -0,0 +1,425 @@
+// Package migrate applies SQL migrations embedded in the application
+// binary, so deploying needs neither a Go toolchain nor the goose CLI.
+//
+// Migrations are files named <version>_<name>.sql in the goose format,
+// with "-- +goose Up" and "-- +goose Down" sections, StatementBegin and
+// StatementEnd around statements containing semicolons and NO TRANSACTION.
+// Applied versions are recorded in goose's version table, so databases
+// migrated with goose keep their state:
+//
+//	//go:embed migrations/*.sql
+//	var migrations embed.FS
+//
+//	m := migrate.New(sqlDB, migrations, migrate.Config{Driver: "sqlite3", Dir: "migrations"})
+//	err := m.Up(ctx)
+//
+// Every operation holds a lock in the database, so instances migrating on
+// boot at the same time apply each migration once.
+package migrate
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft/superkit/db"
+)
+
+// Config configures a Migrator.
+type Config struct {
+	// Driver is the database/sql driver name, "sqlite3", "postgres", "pgx"
+	// or "mysql". Defaults to "sqlite3".
+	Driver string
+	// Dir is the directory of the migrations in the file system. Defaults
+	// to the root.
+	Dir string
+	// SourceDir is the directory of the migrations in the source tree,
+	// where Create writes new ones. Defaults to Dir.
+	SourceDir string
+	// Table is the version table. Defaults to goose_db_version.
+	Table string
+	// LockTimeout is how long to wait for another instance to finish
+	// migrating. Defaults to one minute.
+	LockTimeout time.Duration
+	// StaleLockAge is the age after which a lock is taken to be left
+	// behind by a crashed process and broken. The process holding the lock
+	// renews it every third of it. Defaults to 15 minutes.
+	StaleLockAge time.Duration
+}
+
+// Migrator applies the migrations of a file system to a database.
+type Migrator struct {
+	db   *sql.DB
+	fsys fs.FS
+	cfg  Config
+}
+
+// New returns a Migrator applying the migrations in cfg.Dir of fsys to db.
+func New(db *sql.DB, fsys fs.FS, cfg Config) *Migrator {
+	if cfg.Driver == "" {
+		cfg.Driver = "sqlite3"
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "."
+	}
+	if cfg.SourceDir == "" {
+		cfg.SourceDir = cfg.Dir
+	}
+	if cfg.Table == "" {
+		cfg.Table = "goose_db_version"
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = time.Minute
+	}
+	if cfg.StaleLockAge <= 0 {
+		cfg.StaleLockAge = 15 * time.Minute
+	}
+	return &Migrator{db: db, fsys: fsys, cfg: cfg}
+}
+
+// Status is the state of a migration.
+type Status struct {
+	Version int64
+	Name    string
+	Applied bool
+	// Missing is set for versions applied to the database that have no
+	// migration file.
+	Missing bool
+}
+
+// Status returns the state of every migration, ordered by version.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var statuses []Status
+	err := m.locked(ctx, func(migrations []*migration, applied map[int64]bool) error {
+		for _, mig := range migrations {
+			statuses = append(statuses, Status{Version: mig.Version, Name: mig.Name, Applied: applied[mig.Version]})
+			delete(applied, mig.Version)
+		}
+		for v := range applied {
+			statuses = append(statuses, Status{Version: v, Applied: true, Missing: true})
+		}
+		return nil
+	})
+	slices.SortFunc(statuses, func(a, b Status) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return statuses, err
+}
+
+// Up applies all pending migrations. Pending migrations older than applied
+// ones, for example from a merged branch, are applied too.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.UpTo(ctx, math.MaxInt64)
+}
+
+// UpTo applies the pending migrations up to and including version.
+func (m *Migrator) UpTo(ctx context.Context, version int64) error {
+	return m.locked(ctx, func(migrations []*migration, applied map[int64]bool) error {
+		n := 0
+		for _, mig := range migrations {
+			if mig.Version > version {
+				break
+			}
+			if applied[mig.Version] {
+				continue
+			}
+			if err := m.apply(ctx, mig, true); err != nil {
+				return err
+			}
+			n++
+		}
+		if n == 0 {
+			slog.Info("migrate: no pending migrations")
+		}
+		return nil
+	})
+}
+
+// Down rolls back the latest applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	return m.locked(ctx, func(migrations []*migration, applied map[int64]bool) error {
+		mig, err := latest(migrations, applied)
+		if err != nil || mig == nil {
+			return err
+		}
+		return m.apply(ctx, mig, false)
+	})
+}
+
+// DownTo rolls back the applied migrations newer than version. DownTo(ctx,
+// 0) rolls back all migrations.
+func (m *Migrator) DownTo(ctx context.Context, version int64) error {
+	return m.locked(ctx, func(migrations []*migration, applied map[int64]bool) error {
+		for {
+			mig, err := latest(migrations, applied)
+			if err != nil || mig == nil || mig.Version <= version {
+				return err
+			}
+			if err := m.apply(ctx, mig, false); err != nil {
+				return err
+			}
+			delete(applied, mig.Version)
+		}
+	})
+}
+
+// Redo rolls back the latest applied migration and applies it again.
+func (m *Migrator) Redo(ctx context.Context) error {
+	return m.locked(ctx, func(migrations []*migration, applied map[int64]bool) error {
+		mig, err := latest(migrations, applied)
+		if err != nil || mig == nil {
+			return err
+		}
+		if err := m.apply(ctx, mig, false); err != nil {
+			return err
+		}
+		return m.apply(ctx, mig, true)
+	})
+}
+
+// latest returns the applied migration with the highest version, or nil if
+// none is applied.
+func latest(migrations []*migration, applied map[int64]bool) (*migration, error) {
+	var top int64
+	for v := range applied {
+		top = max(top, v)
+	}
+	if top == 0 {
+		slog.Info("migrate: no applied migrations")
+		return nil, nil
+	}
+	for _, mig := range migrations {
+		if mig.Version == top {
+			return mig, nil
+		}
+	}
+	return nil, fmt.Errorf("migrate: version %d is applied but has no migration file", top)
+}
+
+// apply runs the up or down statements of mig and records the outcome in
+// the version table.
+func (m *Migrator) apply(ctx context.Context, mig *migration, up bool) error {
+	stmts, record, args := mig.up, "insert into "+m.cfg.Table+" (version_id, is_applied) values (?, ?)", []any{mig.Version, true}
+	direction := "up"
+	if !up {
+		stmts, record, args = mig.down, "delete from "+m.cfg.Table+" where version_id = ?", []any{mig.Version}
+		direction = "down"
+	}
+	start := time.Now()
+	err := m.inTx(ctx, !mig.noTx, func(q querier) error {
+		for _, stmt := range stmts {
+			if _, err := q.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		_, err := q.ExecContext(ctx, m.rebind(record), args...)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("migrate: %s %s: %w", direction, mig.Source, err)
+	}
+	slog.Info("migrate: "+direction, "migration", mig.Source, "duration", time.Since(start))
+	return nil
+}
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// inTx calls fn with a transaction that is committed if fn succeeds, or
+// with the database if useTx is false.
+func (m *Migrator) inTx(ctx context.Context, useTx bool, fn func(q querier) error) error {
+	if !useTx {
+		return fn(m.db)
+	}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// locked calls fn with the migrations and the applied versions while
+// holding the migration lock.
+func (m *Migrator) locked(ctx context.Context, fn func(migrations []*migration, applied map[int64]bool) error) error {
+	migrations, err := collect(m.fsys, m.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	if err := m.ensureTables(ctx); err != nil {
+		return err
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	return fn(migrations, applied)
+}
+
+// applied returns the applied versions. As in goose, the latest row of a
+// version tells whether it is applied.
+func (m *Migrator) applied(ctx context.Context) (map[int64]bool, error) {
+	rows, err := m.db.QueryContext(ctx, "select version_id, is_applied from "+m.cfg.Table+" order by id")
+	if err != nil {
+		return nil, fmt.Errorf("migrate: reading versions: %w", err)
+	}
+	defer rows.Close()
+	applied := make(map[int64]bool)
+	for rows.Next() {
+		var (
+			version int64
+			ok      bool
+		)
+		if err := rows.Scan(&version, &ok); err != nil {
+			return nil, fmt.Errorf("migrate: reading versions: %w", err)
+		}
+		if version == 0 {
+			continue
+		}
+		if ok {
+			applied[version] = true
+		} else {
+			delete(applied, version)
+		}
+	}
+	return applied, rows.Err()
+}
+
+// ensureTables creates the version and lock tables. A new version table
+// gets goose's initial version 0 row.
+func (m *Migrator) ensureTables(ctx context.Context) error {
+	for _, ddl := range m.schema() {
+		if _, err := m.db.ExecContext(ctx, ddl); err != nil {
+			return fmt.Errorf("migrate: creating tables: %w", err)
+		}
+	}
+	var n int
+	if err := m.db.QueryRowContext(ctx, "select count(*) from "+m.cfg.Table).Scan(&n); err != nil {
+		return fmt.Errorf("migrate: reading versions: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	_, err := m.db.ExecContext(ctx, m.rebind("insert into "+m.cfg.Table+" (version_id, is_applied) values (?, ?)"), 0, true)
+	return err
+}
+
+func (m *Migrator) schema() []string {
+	versions, locks := m.cfg.Table, m.cfg.Table+"_lock"
+	switch {
+	case db.IsPostgres(m.cfg.Driver):
+		return []string{
+			"create table if not exists " + versions + " (id serial primary key, version_id bigint not null, is_applied boolean not null, tstamp timestamp null default now())",
+			"create table if not exists " + locks + " (id integer primary key, locked_by text not null, locked_at timestamptz not null)",
+		}
+	case m.cfg.Driver == db.DriverMysql:
+		return []string{
+			"create table if not exists " + versions + " (id serial not null, version_id bigint not null, is_applied boolean not null, tstamp timestamp null default current_timestamp, primary key(id))",
+			"create table if not exists " + locks + " (id integer primary key, locked_by varchar(191) not null, locked_at datetime(6) not null)",
+		}
+	}
+	return []string{
+		"create table if not exists " + versions + " (id integer primary key autoincrement, version_id integer not null, is_applied integer not null, tstamp timestamp default (datetime('now')))",
+		"create table if not exists " + locks + " (id integer primary key, locked_by text not null, locked_at datetime not null)",
+	}
+}
+
+// lock takes the migration lock, waiting up to Config.LockTimeout for
+// another process holding it. The lock is a row in the lock table, which
+// works alike on every database, and is renewed until unlock is called so
+// long migrations do not outlive StaleLockAge.
+func (m *Migrator) lock(ctx context.Context) (unlock func(), err error) {
+	table := m.cfg.Table + "_lock"
+	owner := db.OwnerID()
+	insert := "insert into " + table + " (id, locked_by, locked_at) values (1, ?, ?)"
+	if m.cfg.Driver == "mysql" {
+		insert = strings.Replace(insert, "insert", "insert ignore", 1)
+	} else {
+		insert += " on conflict (id) do nothing"
+	}
+	deadline := time.Now().Add(m.cfg.LockTimeout)
+	for {
+		now := time.Now().UTC()
+		if _, err := m.db.ExecContext(ctx, m.rebind("delete from "+table+" where id = 1 and locked_at < ?"), now.Add(-m.cfg.StaleLockAge)); err != nil {
+			return nil, fmt.Errorf("migrate: lock: %w", err)
+		}
+		res, err := m.db.ExecContext(ctx, m.rebind(insert), owner, now)
+		if err != nil {
+			return nil, fmt.Errorf("migrate: lock: %w", err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("migrate: lock: %w", err)
+		} else if n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, errors.New("migrate: timed out waiting for the migration lock held by another process")
+		}
+		slog.Info("migrate: waiting for the migration lock")
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+	stop := m.keepLock(owner)
+	return func() {
+		stop()
+		_, err := m.db.ExecContext(context.WithoutCancel(ctx), m.rebind("delete from "+table+" where id = 1 and locked_by = ?"), owner)
+		if err != nil {
+			slog.Error("migrate: releasing the migration lock failed", "err", err)
+		}
+	}, nil
+}
+
+// keepLock renews the migration lock held by owner until the returned
+// function is called.
+func (m *Migrator) keepLock(owner string) (stop func()) {
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(m.cfg.StaleLockAge / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			res, err := m.db.ExecContext(context.Background(),
+				m.rebind("update "+m.cfg.Table+"_lock set locked_at = ? where id = 1 and locked_by = ?"), time.Now().UTC(), owner)
+			if err != nil {
+				slog.Error("migrate: renewing the migration lock failed", "err", err)
+			} else if n, err := res.RowsAffected(); err == nil && n == 0 {
+				slog.Error("migrate: the migration lock was taken over by another process")
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
+
+// rebind rewrites the ? placeholders of query for the configured driver.
+func (m *Migrator) rebind(query string) string {
+	return db.Rebind(m.cfg.Driver, query)
+}
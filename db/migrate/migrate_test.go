@@ -0,0 +1,172 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const usersMigration = `-- Users of the application.
+-- +goose Up
+create table users(
+	id integer primary key,
+	email text not null
+);
+create index users_email on users(email);
+
+-- +goose StatementBegin
+create trigger users_email_lower after insert on users
+begin
+	update users set email = lower(new.email) where id = new.id;
+end;
+-- +goose StatementEnd
+
+-- +goose Down
+drop table users;
+`
+
+func TestParse(t *testing.T) {
+	m, err := parse("20240610161057_create_users_table.sql", strings.NewReader(usersMigration))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != 20240610161057 || m.Name != "create_users_table" || m.noTx {
+		t.Fatalf("unexpected migration %+v", m)
+	}
+	if len(m.up) != 3 {
+		t.Fatalf("expected 3 up statements got %d: %q", len(m.up), m.up)
+	}
+	if !strings.HasPrefix(m.up[2], "create trigger") || !strings.HasSuffix(m.up[2], "end;") {
+		t.Fatalf("expected the trigger as one statement, got %q", m.up[2])
+	}
+	if !slices.Equal(m.down, []string{"drop table users;"}) {
+		t.Fatalf("unexpected down statements %q", m.down)
+	}
+
+	m, err = parse("2_index.sql", strings.NewReader("-- +goose NO TRANSACTION\n-- +goose Up\ncreate index concurrently a on b(c);\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.noTx || len(m.up) != 1 || len(m.down) != 0 {
+		t.Fatalf("unexpected migration %+v", m)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"init.sql":      "-- +goose Up\nselect 1;\n",
+		"0_zero.sql":    "-- +goose Up\nselect 1;\n",
+		"1_noup.sql":    "create table a(id integer);\n",
+		"2_open.sql":    "-- +goose Up\n-- +goose StatementBegin\nselect 1;\n",
+		"3_end.sql":     "-- +goose Up\n-- +goose StatementEnd\n",
+		"4_semi.sql":    "-- +goose Up\nselect 1\n-- +goose Down\nselect 2;\n",
+		"5_unknown.sql": "-- +goose Up\n-- +goose ENVSUB ON\n",
+		"6_before.sql":  "select 1;\n-- +goose Up\n",
+	}
+	for name, src := range tests {
+		if _, err := parse(name, strings.NewReader(src)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestCollect(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/3_c.sql":   {Data: []byte("-- +goose Up\nselect 3;\n")},
+		"migrations/10_d.sql":  {Data: []byte("-- +goose Up\nselect 10;\n")},
+		"migrations/1_a.sql":   {Data: []byte("-- +goose Up\nselect 1;\n")},
+		"migrations/README.md": {Data: []byte("not a migration")},
+	}
+	migrations, err := collect(fsys, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int64
+	for _, m := range migrations {
+		versions = append(versions, m.Version)
+	}
+	if !slices.Equal(versions, []int64{1, 3, 10}) {
+		t.Fatalf("expected versions sorted numerically, got %v", versions)
+	}
+
+	fsys["migrations/03_again.sql"] = &fstest.MapFile{Data: []byte("-- +goose Up\nselect 3;\n")}
+	if _, err := collect(fsys, "migrations"); err == nil {
+		t.Fatal("expected error for duplicate versions")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "migrations")
+	now := time.Date(2026, 10, 18, 14, 30, 5, 0, time.UTC)
+	path, err := Create(dir, "Add jobs table", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "20261018143005_add_jobs_table.sql"); path != want {
+		t.Fatalf("expected %s got %s", want, path)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parse(filepath.Base(path), strings.NewReader(string(b))); err != nil {
+		t.Fatalf("created migration does not parse: %v", err)
+	}
+	if _, err := Create(dir, "add jobs table", now); err == nil {
+		t.Fatal("expected error for existing migration")
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	m := New(nil, fstest.MapFS{}, Config{})
+	for _, args := range [][]string{nil, {"sideways"}, {"up-to"}, {"up-to", "latest"}, {"down-to", "-1"}, {"create"}} {
+		if err := m.Run(context.Background(), args, nil); err == nil {
+			t.Errorf("%q: expected error", args)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	m := New(nil, nil, Config{Driver: "pgx"})
+	if got, want := m.rebind("delete from t where a = ? and b = ?"), "delete from t where a = $1 and b = $2"; got != want {
+		t.Fatalf("expected %q got %q", want, got)
+	}
+}
+
+func TestLockRenewed(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "app.db")+"?_busy_timeout=5000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	cfg := Config{LockTimeout: time.Millisecond, StaleLockAge: 300 * time.Millisecond}
+	m := New(db, fstest.MapFS{}, cfg)
+	ctx := context.Background()
+
+	err = m.locked(ctx, func([]*migration, map[int64]bool) error {
+		// Outlive StaleLockAge: the lock is renewed, so another process
+		// does not break it.
+		time.Sleep(time.Second)
+		if _, err := New(db, fstest.MapFS{}, cfg).lock(ctx); err == nil {
+			t.Error("expected the renewed lock to be held")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := New(db, fstest.MapFS{}, cfg).lock(ctx)
+	if err != nil {
+		t.Fatalf("expected the lock to be released, got %v", err)
+	}
+	unlock()
+}
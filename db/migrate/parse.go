@@ -0,0 +1,167 @@
+package migrate
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// migration is a parsed migration file.
+type migration struct {
+	// Version is the number the file name starts with.
+	Version int64
+	// Name is the rest of the file name, e.g. create_users_table.
+	Name string
+	// Source is the file name.
+	Source string
+
+	up, down []string
+	// noTx runs the statements outside a transaction
+	noTx bool
+}
+
+// collect parses the .sql files in dir of fsys, sorted by version.
+func collect(fsys fs.FS, dir string) ([]*migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("migrate: %w", err)
+	}
+	var migrations []*migration
+	seen := make(map[int64]string)
+	for _, e := range entries {
+		if e.IsDir() || path.Ext(e.Name()) != ".sql" {
+			continue
+		}
+		f, err := fsys.Open(path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("migrate: %w", err)
+		}
+		m, err := parse(e.Name(), f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[m.Version]; ok {
+			return nil, fmt.Errorf("migrate: %s and %s have the same version", other, m.Source)
+		}
+		seen[m.Version] = m.Source
+		migrations = append(migrations, m)
+	}
+	slices.SortFunc(migrations, func(a, b *migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return migrations, nil
+}
+
+// parse parses a migration in the goose format: the statements following
+// "-- +goose Up" migrate up and those following "-- +goose Down" migrate
+// down. Statements end with a line ending in a semicolon, except between
+// "-- +goose StatementBegin" and "-- +goose StatementEnd", which enclose
+// statements containing semicolons such as function bodies. "-- +goose NO
+// TRANSACTION" runs the migration outside a transaction.
+func parse(name string, r io.Reader) (*migration, error) {
+	prefix, rest, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), "_")
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if !ok || err != nil || version <= 0 {
+		return nil, fmt.Errorf("migrate: %s: file name must start with a positive version followed by _", name)
+	}
+	m := &migration{Version: version, Name: rest, Source: name}
+	fail := func(line int, format string, args ...any) error {
+		return fmt.Errorf("migrate: %s:%d: %s", name, line, fmt.Sprintf(format, args...))
+	}
+
+	var (
+		section *[]string
+		inBlock bool
+		buf     strings.Builder
+	)
+	flush := func() {
+		if stmt := strings.TrimSpace(buf.String()); hasSQL(stmt) {
+			*section = append(*section, stmt)
+		}
+		buf.Reset()
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	n := 0
+	for sc.Scan() {
+		n++
+		line := sc.Text()
+		trimmed := strings.TrimSpace(line)
+		if annotation, ok := strings.CutPrefix(trimmed, "-- +goose "); ok {
+			switch annotation = strings.TrimSpace(annotation); {
+			case strings.EqualFold(annotation, "Up"), strings.EqualFold(annotation, "Down"):
+				if inBlock {
+					return nil, fail(n, "missing StatementEnd before %s", annotation)
+				}
+				if section != nil {
+					if hasSQL(buf.String()) {
+						return nil, fail(n, "statement not terminated by a semicolon")
+					}
+					buf.Reset()
+				}
+				section = &m.up
+				if strings.EqualFold(annotation, "Down") {
+					section = &m.down
+				}
+			case strings.EqualFold(annotation, "StatementBegin"):
+				if section == nil || inBlock {
+					return nil, fail(n, "unexpected StatementBegin")
+				}
+				inBlock = true
+			case strings.EqualFold(annotation, "StatementEnd"):
+				if !inBlock {
+					return nil, fail(n, "StatementEnd without StatementBegin")
+				}
+				inBlock = false
+				flush()
+			case strings.EqualFold(annotation, "NO TRANSACTION"):
+				m.noTx = true
+			default:
+				return nil, fail(n, "unknown annotation %q", annotation)
+			}
+			continue
+		}
+		if section == nil {
+			if hasSQL(trimmed) {
+				return nil, fail(n, "statement before -- +goose Up")
+			}
+			continue
+		}
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+		if !inBlock && strings.HasSuffix(trimmed, ";") && !strings.HasPrefix(trimmed, "--") {
+			flush()
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("migrate: %s: %w", name, err)
+	}
+	switch {
+	case section == nil:
+		return nil, fmt.Errorf("migrate: %s: missing -- +goose Up", name)
+	case inBlock:
+		return nil, fail(n, "missing StatementEnd")
+	case hasSQL(buf.String()):
+		return nil, fail(n, "statement not terminated by a semicolon")
+	}
+	return m, nil
+}
+
+// hasSQL reports whether s contains anything but comments and blank lines.
+func hasSQL(s string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return true
+		}
+	}
+	return false
+}
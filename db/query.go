@@ -0,0 +1,47 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// IsPostgres reports whether driver is a database/sql driver name for
+// postgres, "postgres" (lib/pq) or "pgx".
+func IsPostgres(driver string) bool {
+	return driver == DriverPostgres || driver == "pgx"
+}
+
+// Rebind rewrites the ? placeholders of query for the database of driver:
+// postgres gets $1, $2, ... and other databases keep ?. Queries must not
+// contain ? in string literals.
+func Rebind(driver, query string) string {
+	if !IsPostgres(driver) {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// OwnerID returns an ID identifying the locks and leases a process holds in
+// the database, made of the host name, the process ID and a random suffix so
+// several holders in one process differ.
+func OwnerID() string {
+	host, _ := os.Hostname()
+	var b [4]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(b[:]))
+}
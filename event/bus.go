@@ -0,0 +1,475 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Bus delivers emitted events to the handlers subscribed to their topic.
+// The package level functions use a default Bus; applications and tests can
+// create their own with New.
+type Bus struct {
+	cfg Config
+
+	mu      sync.RWMutex
+	subs    map[string][]Subscription // by topic pattern
+	trie    *topicTrie                // index used to match emitted topics
+	eventch chan event
+
+	// sendMu is held for reading while sending to eventch and for writing
+	// while closing it, so emits never send on a closed channel.
+	sendMu sync.RWMutex
+
+	// context to cancel running handlers on stop
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// wait group to wait for handler goroutines spawned by the bus
+	wg sync.WaitGroup
+
+	// closed when the start loop returned, so no more handlers are spawned
+	loopDone chan struct{}
+
+	// ensure stop is only performed once
+	stopOnce sync.Once
+
+	// indicator the bus has been stopped
+	closed atomic.Bool
+
+	// optional observer notified about bus activity
+	observer atomic.Pointer[Observer]
+
+	// running totals exposed through Counters
+	queued  atomic.Uint64
+	dropped atomic.Uint64
+
+	// per-topic statistics exposed through Stats
+	stats stats
+
+	// number of queued events and running handlers, and the channel closed
+	// when it drops to zero, used by Flush
+	idleMu sync.Mutex
+	active int64
+	idle   chan struct{}
+
+	// requests waiting for a reply
+	replies replies
+
+	// events scheduled with EmitAt
+	schedule schedule
+
+	// optional transport connecting the bus to remote buses, guarded by mu
+	transport Transport
+
+	// error-returning handlers by subscription ID, so dead letters can be
+	// replayed
+	retryHandlers sync.Map // map[uint64]retryHandler
+
+	// dead letters kept in memory
+	deadLetters deadLetterStore
+}
+
+type event struct {
+	topic   string
+	message any
+	// context the event was emitted with, if any, whose allowlisted values
+	// are propagated to the handlers
+	ctx context.Context
+	// tracks the handlers of an event emitted with EmitAndWait
+	wait *sync.WaitGroup
+	// set for events received through the transport, which are not
+	// published again
+	remote bool
+}
+
+// global counter for subscription IDs
+var subIDCounter atomic.Uint64
+
+// New returns a running Bus configured by cfg.
+func New(cfg Config) *Bus {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = 128
+	}
+	if cfg.BlockTimeout <= 0 {
+		cfg.BlockTimeout = time.Second
+	}
+	if cfg.RequestTimeout <= 0 {
+		cfg.RequestTimeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Bus{
+		cfg:      cfg,
+		subs:     make(map[string][]Subscription),
+		trie:     newTopicTrie(),
+		eventch:  make(chan event, cfg.BufferSize),
+		ctx:      ctx,
+		cancel:   cancel,
+		loopDone: make(chan struct{}),
+		schedule: schedule{wake: make(chan struct{}, 1)},
+	}
+	go b.start()
+	go b.runSchedule()
+	return b
+}
+
+// Emit an event to the given topic. When the buffer is full the configured
+// overflow policy applies; events that cannot be queued are logged and
+// dropped.
+func (b *Bus) Emit(topic string, event any) {
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.BlockTimeout)
+	defer cancel()
+	if err := b.emit(ctx, newEvent(topic, event), true); err != nil && !errors.Is(err, ErrStopped) {
+		slog.Warn("dropping event", "topic", topic, "err", err)
+	}
+}
+
+// EmitContext emits an event to the given topic and reports whether it was
+// queued. Under the Block policy it waits until there is room in the buffer
+// or ctx is done, in which case the context error is returned. Handlers
+// receive the values of ctx allowlisted with PropagateContext, but not its
+// cancellation.
+func (b *Bus) EmitContext(ctx context.Context, topic string, event any) error {
+	evt := newEvent(topic, event)
+	evt.ctx = ctx
+	return b.emit(ctx, evt, true)
+}
+
+// TryEmit emits an event to the given topic without ever blocking. It
+// returns ErrBufferFull if the event could not be queued.
+func (b *Bus) TryEmit(topic string, event any) error {
+	return b.emit(context.Background(), newEvent(topic, event), false)
+}
+
+// EmitAndWait emits an event to the given topic like EmitContext and waits
+// until every handler subscribed to it has returned, or ctx is done.
+func (b *Bus) EmitAndWait(ctx context.Context, topic string, event any) error {
+	evt := newEvent(topic, event)
+	evt.ctx = ctx
+	evt.wait = new(sync.WaitGroup)
+	// The event itself holds the wait group until its handlers were
+	// dispatched, so Wait cannot return before they started.
+	evt.wait.Add(1)
+	if err := b.emit(ctx, evt, true); err != nil {
+		return err
+	}
+	return waitContext(ctx, evt.wait)
+}
+
+// Flush waits until all queued events have been handled and no handler is
+// running, or ctx is done. Events emitted while Flush waits are waited for
+// as well.
+func (b *Bus) Flush(ctx context.Context) error {
+	b.idleMu.Lock()
+	if b.active == 0 {
+		b.idleMu.Unlock()
+		return nil
+	}
+	if b.idle == nil {
+		b.idle = make(chan struct{})
+	}
+	idle := b.idle
+	b.idleMu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Subscribe a HandlerFunc to the given topic. See the package level
+// Subscribe for topic patterns and options.
+func (b *Bus) Subscribe(topic string, h HandlerFunc, opts ...SubscribeOption) Subscription {
+	return b.subscribeID(subIDCounter.Add(1), topic, h, opts...)
+}
+
+// Unsubscribe unsubscribes the given Subscription from its topic.
+func (b *Bus) Unsubscribe(sub Subscription) {
+	b.retryHandlers.Delete(sub.ID)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub.Topic]; ok {
+		b.subs[sub.Topic] = slices.DeleteFunc(b.subs[sub.Topic], func(s Subscription) bool {
+			if s.ID != sub.ID {
+				return false
+			}
+			// queued events are still handled
+			s.delivery.close()
+			return true
+		})
+		b.trie.remove(sub)
+		if len(b.subs[sub.Topic]) == 0 {
+			delete(b.subs, sub.Topic)
+			if err := b.register(); err != nil {
+				slog.Warn("registering topics with transport", "err", err)
+			}
+		}
+	}
+}
+
+// Observe installs o as the observer of the bus, replacing any previously
+// installed observer. Passing nil removes the observer.
+func (b *Bus) Observe(o Observer) {
+	if o == nil {
+		b.observer.Store(nil)
+		return
+	}
+	b.observer.Store(&o)
+}
+
+// Counters returns the running totals of the bus.
+func (b *Bus) Counters() Counters {
+	return Counters{
+		Queued:  b.queued.Load(),
+		Dropped: b.dropped.Load(),
+		Pending: len(b.eventch),
+	}
+}
+
+// Stop stops the bus, waiting for in-flight handlers to complete. Events
+// still in the buffer and events scheduled with EmitAt are discarded.
+func (b *Bus) Stop() {
+	b.stopOnce.Do(func() {
+		// mark closed so emits can be dropped
+		b.closed.Store(true)
+
+		// cancel context to notify handlers and unblock blocked emits
+		b.cancel()
+
+		// close event channel to stop the start loop
+		// it's safe to close here because stopOnce ensures this runs once
+		// and sendMu ensures no emit is sending
+		b.sendMu.Lock()
+		close(b.eventch)
+		b.sendMu.Unlock()
+		<-b.loopDone
+
+		// stop receiving remote events
+		b.mu.RLock()
+		t := b.transport
+		b.mu.RUnlock()
+		if t != nil {
+			if err := t.Close(); err != nil {
+				slog.Warn("closing event transport", "err", err)
+			}
+		}
+
+		// wait for queued and in-flight handlers to finish
+		b.wg.Wait()
+
+		// release waiters of discarded events
+		for evt := range b.eventch {
+			b.discard(evt)
+		}
+
+		// stop the workers and clear subscriptions
+		b.mu.Lock()
+		for _, subs := range b.subs {
+			for _, sub := range subs {
+				sub.delivery.close()
+			}
+		}
+		b.subs = make(map[string][]Subscription)
+		b.trie = newTopicTrie()
+		b.mu.Unlock()
+	})
+}
+
+func newEvent(topic string, message any) event {
+	return event{topic: topic, message: message}
+}
+
+func (b *Bus) start() {
+	defer close(b.loopDone)
+	for {
+		select {
+		case <-b.ctx.Done():
+			// context cancelled -> shutdown
+			return
+		case evt, ok := <-b.eventch:
+			if !ok {
+				return
+			}
+
+			// collect matching handlers under read lock so we can iterate safely
+			b.mu.RLock()
+			handlers := b.trie.match(evt.topic)
+			t := b.transport
+			b.mu.RUnlock()
+			b.publish(t, evt)
+
+			ctx := b.handlerContext(evt)
+			for _, sub := range handlers {
+				if evt.wait != nil {
+					evt.wait.Add(1)
+				}
+				// the handler context is cancelled with the bus
+				b.dispatch(sub, evt.message, func() {
+					if evt.wait != nil {
+						defer evt.wait.Done()
+					}
+					b.invoke(ctx, sub, evt.topic, evt.message)
+				})
+			}
+			// the handlers hold the counts from here on
+			b.discard(evt)
+		}
+	}
+}
+
+// discard releases what an event held while it was queued.
+func (b *Bus) discard(evt event) {
+	if evt.wait != nil {
+		evt.wait.Done()
+	}
+	b.track(-1)
+}
+
+// track adds delta to the number of queued events and running handlers.
+func (b *Bus) track(delta int64) {
+	b.idleMu.Lock()
+	defer b.idleMu.Unlock()
+	b.active += delta
+	if b.active == 0 && b.idle != nil {
+		close(b.idle)
+		b.idle = nil
+	}
+}
+
+// emit queues an event according to the overflow policy. If mayBlock is
+// false the Block policy behaves like Error.
+func (b *Bus) emit(ctx context.Context, evt event, mayBlock bool) error {
+	b.sendMu.RLock()
+	defer b.sendMu.RUnlock()
+
+	// if the bus has been stopped, drop events
+	if b.closed.Load() {
+		slog.Debug("dropping event because bus is stopped", "topic", evt.topic)
+		return ErrStopped
+	}
+
+	// count the event before it can be picked up
+	b.track(1)
+	o := b.getObserver()
+	for {
+		// Try to send without blocking first.
+		select {
+		case b.eventch <- evt:
+			b.queued.Add(1)
+			b.stats.emitted(evt.topic)
+			if o != nil {
+				o.Emitted(evt.topic)
+			}
+			return nil
+		default:
+		}
+
+		switch b.cfg.Overflow {
+		case DropOldest:
+			// Evict the oldest event and retry; another emitter may win the
+			// freed slot, in which case we evict again.
+			select {
+			case old := <-b.eventch:
+				b.discard(old)
+				b.dropped.Add(1)
+				b.stats.dropped(old.topic)
+				if o != nil {
+					o.Dropped(old.topic)
+				}
+			default:
+			}
+			continue
+		case Block:
+			if !mayBlock {
+				break
+			}
+			select {
+			case b.eventch <- evt:
+				b.queued.Add(1)
+				b.stats.emitted(evt.topic)
+				if o != nil {
+					o.Emitted(evt.topic)
+				}
+				return nil
+			case <-ctx.Done():
+				b.drop(o, evt)
+				return ctx.Err()
+			case <-b.ctx.Done():
+				b.track(-1)
+				return ErrStopped
+			}
+		}
+		b.drop(o, evt)
+		return ErrBufferFull
+	}
+}
+
+// drop accounts for an event that could not be queued.
+func (b *Bus) drop(o Observer, evt event) {
+	b.track(-1)
+	b.dropped.Add(1)
+	b.stats.dropped(evt.topic)
+	if o != nil {
+		o.Dropped(evt.topic)
+	}
+}
+
+func (b *Bus) getObserver() Observer {
+	if p := b.observer.Load(); p != nil {
+		return *p
+	}
+	return nil
+}
+
+func (b *Bus) subscribeID(id uint64, topic string, h HandlerFunc, opts ...SubscribeOption) Subscription {
+	validatePattern(topic)
+	var o subscribeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub := Subscription{
+		ID:         id,
+		CreatedAt:  time.Now().UnixNano(),
+		Topic:      topic,
+		Fn:         h,
+		delivery:   newDelivery(o),
+		middleware: o.middleware,
+	}
+
+	_, known := b.subs[topic]
+	b.subs[topic] = append(b.subs[topic], sub)
+	b.trie.insert(sub)
+	if !known {
+		if err := b.register(); err != nil {
+			slog.Warn("registering topics with transport", "err", err)
+		}
+	}
+	return sub
+}
+
+// waitContext waits for wg or until ctx is done.
+func waitContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,50 @@
+package event
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+)
+
+// payloadTypes maps topics to the payload type events on that topic are
+// decoded into when they are read back from outside the process.
+var payloadTypes sync.Map // map[string]reflect.Type
+
+// RegisterType registers the type of v as the payload type of topic. Events
+// that are stored or sent elsewhere, such as through the outbox, are encoded
+// as JSON and decoded into this type before they reach subscribers. Payloads
+// of unregistered topics are decoded into the generic JSON types (maps,
+// slices, float64, ...). NewTopic registers its type automatically.
+func RegisterType(topic string, v any) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return
+	}
+	payloadTypes.Store(topic, t)
+}
+
+func registerType(topic string, t reflect.Type) {
+	// JSON cannot decode into interface types other than any.
+	if t.Kind() == reflect.Interface {
+		return
+	}
+	payloadTypes.Store(topic, t)
+}
+
+func encodePayload(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func decodePayload(topic string, data []byte) (any, error) {
+	t, ok := payloadTypes.Load(topic)
+	if !ok {
+		var v any
+		err := json.Unmarshal(data, &v)
+		return v, err
+	}
+	ptr := reflect.New(t.(reflect.Type))
+	if err := json.Unmarshal(data, ptr.Interface()); err != nil {
+		return nil, err
+	}
+	return ptr.Elem().Interface(), nil
+}
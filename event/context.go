@@ -0,0 +1,73 @@
+package event
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// propagatedKeys is the allowlist of context keys copied from the context
+// passed to EmitContext into the context of the handlers.
+var propagatedKeys sync.Map // map[any]struct{}
+
+type loggerKey struct{}
+
+func init() {
+	PropagateContext(loggerKey{})
+}
+
+// PropagateContext adds keys to the context values copied from the context
+// passed to EmitContext, EmitAndWait or Request into the context handlers
+// receive. Packages owning a context key register it, for example kit for
+// the authenticated user and kit/trace for the current span; applications
+// add their own such as the request ID:
+//
+//	event.PropagateContext(chimiddleware.RequestIDKey)
+//
+// Only allowlisted values are copied. Cancellation and deadlines of the
+// emitting context are not: handlers keep running after the request that
+// emitted the event has finished, until the bus is stopped.
+func PropagateContext(keys ...any) {
+	for _, key := range keys {
+		propagatedKeys.Store(key, struct{}{})
+	}
+}
+
+// ContextWithLogger returns a copy of ctx carrying l. The logger is
+// propagated to handlers and used by the Logger middleware.
+func ContextWithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or slog.Default.
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok && l != nil {
+		return l
+	}
+	return slog.Default()
+}
+
+// propagatedContext is the context of a handler. It is cancelled with the
+// bus but looks up allowlisted values in the context the event was emitted
+// with.
+type propagatedContext struct {
+	context.Context
+	src context.Context
+}
+
+func (c propagatedContext) Value(key any) any {
+	if _, ok := propagatedKeys.Load(key); ok {
+		if v := c.src.Value(key); v != nil {
+			return v
+		}
+	}
+	return c.Context.Value(key)
+}
+
+// handlerContext returns the context handlers of evt run with.
+func (b *Bus) handlerContext(evt event) context.Context {
+	if evt.ctx == nil {
+		return b.ctx
+	}
+	return propagatedContext{Context: b.ctx, src: evt.ctx}
+}
@@ -0,0 +1,81 @@
+package event
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type requestIDKey struct{}
+
+type secretKey struct{}
+
+func TestEmitContextPropagation(t *testing.T) {
+	PropagateContext(requestIDKey{})
+
+	b := New(Config{})
+	defer b.Stop()
+
+	type seen struct {
+		requestID, secret any
+		logger            *slog.Logger
+		err               error
+	}
+	got := make(chan seen, 1)
+	b.Subscribe("foo", func(ctx context.Context, _ any) {
+		// the emitting request has finished by now
+		time.Sleep(20 * time.Millisecond)
+		got <- seen{
+			requestID: ctx.Value(requestIDKey{}),
+			secret:    ctx.Value(secretKey{}),
+			logger:    LoggerFromContext(ctx),
+			err:       ctx.Err(),
+		}
+	})
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = context.WithValue(ctx, requestIDKey{}, "req-1")
+	ctx = context.WithValue(ctx, secretKey{}, "s3cr3t")
+	ctx = ContextWithLogger(ctx, logger)
+	if err := b.EmitContext(ctx, "foo", 1); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	s := <-got
+	if s.requestID != "req-1" {
+		t.Fatalf("expected request ID to be propagated, got %v", s.requestID)
+	}
+	if s.secret != nil {
+		t.Fatalf("expected value not allowlisted to be dropped, got %v", s.secret)
+	}
+	if s.logger != logger {
+		t.Fatal("expected logger to be propagated")
+	}
+	if s.err != nil {
+		t.Fatalf("expected handler context to outlive the request, got %v", s.err)
+	}
+}
+
+func TestEmitContextCancelledOnStop(t *testing.T) {
+	b := New(Config{})
+
+	started := make(chan struct{})
+	done := make(chan error, 1)
+	b.Subscribe("foo", func(ctx context.Context, _ any) {
+		close(started)
+		<-ctx.Done()
+		done <- ctx.Err()
+	})
+	if err := b.EmitContext(context.Background(), "foo", 1); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	b.Stop()
+	if err := <-done; err == nil {
+		t.Fatal("expected handler context to be cancelled on stop")
+	}
+}
@@ -0,0 +1,213 @@
+package event
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SubscribeOption configures how events are delivered to a subscription.
+// Without options every event is handled in its own goroutine.
+type SubscribeOption func(*subscribeOptions)
+
+type subscribeOptions struct {
+	workers    int
+	partitions int
+	key        func(event any) string
+	queueSize  int
+	middleware []Middleware
+}
+
+// Serial delivers events to the subscription one at a time, in the order
+// they were emitted.
+func Serial() SubscribeOption {
+	return Workers(1)
+}
+
+// Workers delivers events to the subscription on a pool of n workers, so at
+// most n events are handled concurrently. Events are not ordered.
+func Workers(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.workers = max(n, 1)
+		o.partitions = 0
+		o.key = nil
+	}
+}
+
+// PartitionBy delivers events to the subscription on n workers, routing
+// events with the same key to the same worker. Events sharing a key, for
+// example the same user ID, are handled in order; events with different
+// keys may be handled concurrently.
+func PartitionBy(n int, key func(event any) string) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.workers = 0
+		o.partitions = max(n, 1)
+		o.key = key
+	}
+}
+
+// QueueSize sets how many events wait for a worker of the subscription
+// before delivery blocks, which in turn fills the event buffer and applies
+// its overflow policy. Defaults to 256. It only applies together with
+// Serial, Workers or PartitionBy.
+func QueueSize(n int) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.queueSize = n
+	}
+}
+
+// WithMiddleware wraps the handler of the subscription with mw. It runs
+// inside the middleware installed with Use.
+func WithMiddleware(mw ...Middleware) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.middleware = append(o.middleware, mw...)
+	}
+}
+
+// delivery runs the jobs of a subscription on its workers.
+type delivery struct {
+	opts subscribeOptions
+
+	// mu is held for reading while enqueueing and for writing while closing
+	// the queues, so jobs are never sent on a closed queue.
+	mu     sync.RWMutex
+	closed bool
+	queues []chan func()
+	// stopping is closed before mu is locked for closing, so enqueues
+	// blocked on a full queue let go of the read lock.
+	stopping chan struct{}
+	stopOnce sync.Once
+}
+
+// newDelivery returns the delivery for o, or nil if events should be
+// handled in their own goroutine.
+func newDelivery(o subscribeOptions) *delivery {
+	if o.workers == 0 && o.partitions == 0 {
+		return nil
+	}
+	if o.queueSize <= 0 {
+		o.queueSize = 256
+	}
+	return startDelivery(o)
+}
+
+func startDelivery(o subscribeOptions) *delivery {
+	d := &delivery{opts: o, stopping: make(chan struct{})}
+	if o.partitions > 0 {
+		d.queues = make([]chan func(), o.partitions)
+		for i := range d.queues {
+			d.queues[i] = make(chan func(), o.queueSize)
+			go work(d.queues[i])
+		}
+		return d
+	}
+	// a worker pool shares a single queue
+	d.queues = []chan func(){make(chan func(), o.queueSize)}
+	for range o.workers {
+		go work(d.queues[0])
+	}
+	return d
+}
+
+func work(queue chan func()) {
+	for job := range queue {
+		job()
+	}
+}
+
+// restart returns a new delivery with the same options.
+func (d *delivery) restart() *delivery {
+	if d == nil {
+		return nil
+	}
+	return startDelivery(d.opts)
+}
+
+// enqueue queues job for the worker responsible for msg, blocking while the
+// queue is full. It reports false if the delivery was closed or is being
+// closed.
+func (d *delivery) enqueue(msg any, job func()) bool {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	if d.closed {
+		return false
+	}
+	queue := d.queues[0]
+	if d.opts.key != nil {
+		h := fnv.New32a()
+		h.Write([]byte(d.opts.key(msg)))
+		queue = d.queues[h.Sum32()%uint32(len(d.queues))]
+	}
+	select {
+	case queue <- job:
+		return true
+	case <-d.stopping:
+		return false
+	}
+}
+
+// close stops the workers once they have run the queued jobs.
+func (d *delivery) close() {
+	if d == nil {
+		return
+	}
+	d.stopOnce.Do(func() { close(d.stopping) })
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	d.closed = true
+	for _, queue := range d.queues {
+		close(queue)
+	}
+}
+
+// dispatch runs fn for the delivery of msg to s, either in its own goroutine
+// or on the workers of the subscription. fn is tracked by the bus's
+// WaitGroup, so Stop waits for queued and in-flight handlers.
+func (b *Bus) dispatch(s Subscription, msg any, fn func()) {
+	b.wg.Add(1)
+	b.track(1)
+	run := func() {
+		defer b.wg.Done()
+		defer b.track(-1)
+		fn()
+	}
+	// If the subscription was unsubscribed after it matched, its workers
+	// are gone; deliver this last event in its own goroutine.
+	if s.delivery == nil || !s.delivery.enqueue(msg, run) {
+		go run()
+	}
+}
+
+// invoke calls the handler of s for an event on topic through the
+// middleware, notifying the observer. It reports whether middleware such as
+// Recover or Retry marked the invocation as failed.
+func (b *Bus) invoke(ctx context.Context, s Subscription, topic string, msg any) bool {
+	o := b.getObserver()
+	if o != nil {
+		o.HandlerStarted(topic)
+	}
+	failed := new(atomic.Bool)
+	defer func(start time.Time) {
+		d := time.Since(start)
+		b.stats.handled(topic, d)
+		if failed.Load() {
+			b.stats.failed(topic)
+		}
+		if o != nil {
+			o.HandlerFinished(topic, d)
+		}
+	}(time.Now())
+	h := chain(s.Fn, s.middleware)
+	if global := globalMiddleware.Load(); global != nil {
+		h = chain(h, *global)
+	}
+	ctx = context.WithValue(ctx, topicKey{}, topic)
+	ctx = context.WithValue(ctx, busKey{}, b)
+	h(context.WithValue(ctx, failureKey{}, failed), msg)
+	return failed.Load()
+}
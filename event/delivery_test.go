@@ -0,0 +1,142 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSerialDelivery(t *testing.T) {
+	s := New(Config{BufferSize: 256})
+
+	var got []int
+	s.Subscribe("delivery.serial", func(_ context.Context, event any) {
+		time.Sleep(time.Microsecond)
+		got = append(got, event.(int))
+	}, Serial())
+	for i := range 100 {
+		if err := s.EmitContext(context.Background(), "delivery.serial", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	flush(t, s)
+	s.Stop()
+
+	if len(got) != 100 {
+		t.Fatalf("expected 100 events got %d", len(got))
+	}
+	for i, v := range got {
+		if v != i {
+			t.Fatalf("expected events in order, got %v", got)
+		}
+	}
+}
+
+func TestWorkersBoundConcurrency(t *testing.T) {
+	s := New(Config{BufferSize: 256})
+
+	var running, peak atomic.Int32
+	var handled atomic.Int32
+	s.Subscribe("delivery.pool", func(context.Context, any) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		running.Add(-1)
+		handled.Add(1)
+	}, Workers(3))
+	for i := range 50 {
+		if err := s.EmitContext(context.Background(), "delivery.pool", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	flush(t, s)
+	s.Stop()
+
+	if handled.Load() != 50 {
+		t.Errorf("expected stop to drain all 50 events, handled %d", handled.Load())
+	}
+	if peak.Load() > 3 {
+		t.Errorf("expected at most 3 concurrent handlers, got %d", peak.Load())
+	}
+}
+
+func TestPartitionByOrdersPerKey(t *testing.T) {
+	s := New(Config{BufferSize: 256})
+
+	type msg struct {
+		user string
+		seq  int
+	}
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	s.Subscribe("delivery.partition", func(_ context.Context, event any) {
+		m := event.(msg)
+		mu.Lock()
+		got[m.user] = append(got[m.user], m.seq)
+		mu.Unlock()
+	}, PartitionBy(4, func(event any) string { return event.(msg).user }))
+	for seq := range 20 {
+		for u := range 5 {
+			if err := s.EmitContext(context.Background(), "delivery.partition", msg{fmt.Sprint(u), seq}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	flush(t, s)
+	s.Stop()
+
+	for user, seqs := range got {
+		if len(seqs) != 20 {
+			t.Fatalf("user %s: expected 20 events got %d", user, len(seqs))
+		}
+		for i, seq := range seqs {
+			if seq != i {
+				t.Fatalf("user %s: expected events in order, got %v", user, seqs)
+			}
+		}
+	}
+}
+
+func flush(t *testing.T, b *Bus) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCloseWhileEnqueueBlocked(t *testing.T) {
+	d := newDelivery(subscribeOptions{workers: 1, queueSize: 1})
+	release := make(chan struct{})
+	d.enqueue(nil, func() { <-release }) // running
+	d.enqueue(nil, func() {})            // queued
+
+	queued := make(chan bool)
+	go func() { queued <- d.enqueue(nil, func() {}) }()
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		d.close()
+		close(closed)
+	}()
+	select {
+	case ok := <-queued:
+		if ok {
+			t.Error("expected the blocked enqueue to fail")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("enqueue still blocked by close")
+	}
+	<-closed
+	close(release)
+}
@@ -2,199 +2,223 @@ package event
 
 import (
 	"context"
-	"slices"
-	"sync"
+	"errors"
 	"sync/atomic"
 	"time"
-
-	"log/slog"
 )
 
 // HandlerFunc is the function being called when receiving an event.
 type HandlerFunc func(context.Context, any)
 
-// Emit an event to the given topic
-func Emit(topic string, event any) {
-	if stream == nil {
-		// defensive: should not happen because init() creates the stream
-		slog.Warn("event stream not initialized; dropping event", "topic", topic)
-		return
-	}
-	stream.emit(topic, event)
-}
+var (
+	// ErrBufferFull is returned when an event could not be queued because
+	// the buffer is full.
+	ErrBufferFull = errors.New("event: buffer full")
+	// ErrStopped is returned when emitting to a stopped Bus.
+	ErrStopped = errors.New("event: bus stopped")
+)
 
-// Subscribe a HandlerFunc to the given topic.
-// A Subscription is returned that can be used to unsubscribe from the topic.
-func Subscribe(topic string, h HandlerFunc) Subscription {
-	return stream.subscribe(topic, h)
+// OverflowPolicy decides what happens when an event is emitted while the
+// buffer is full.
+type OverflowPolicy int
+
+const (
+	// DropNewest drops the event being emitted. This is the default.
+	DropNewest OverflowPolicy = iota
+	// DropOldest drops the oldest buffered event to make room.
+	DropOldest
+	// Block waits for room in the buffer. Emit waits at most
+	// Config.BlockTimeout, EmitContext until its context is done.
+	Block
+	// Error rejects the event with ErrBufferFull. Emit logs the error.
+	Error
+)
+
+// Config configures a Bus.
+type Config struct {
+	// BufferSize is the number of events buffered before the overflow
+	// policy applies. Defaults to 128.
+	BufferSize int
+	// Overflow is the policy applied when the buffer is full.
+	Overflow OverflowPolicy
+	// BlockTimeout bounds how long Emit blocks under the Block policy.
+	// Defaults to one second.
+	BlockTimeout time.Duration
+	// RequestTimeout bounds Request calls whose context has no deadline.
+	// Defaults to 10 seconds.
+	RequestTimeout time.Duration
 }
 
-// Unsubscribe unsubscribes the given Subscription from its topic.
-func Unsubscribe(sub Subscription) {
-	stream.unsubscribe(sub)
+// Counters are running totals of a Bus.
+type Counters struct {
+	// Queued is the number of events accepted into the buffer.
+	Queued uint64 `json:"queued"`
+	// Dropped is the number of events that were dropped, either rejected on
+	// emit or evicted from the buffer by DropOldest.
+	Dropped uint64 `json:"dropped"`
+	// Pending is the number of events currently waiting in the buffer.
+	Pending int `json:"pending"`
 }
 
-// Stop stops the event stream, waiting for in-flight handlers to complete.
-func Stop() {
-	if stream != nil {
-		stream.stop()
+// Configure replaces the default bus with one configured by cfg. Existing
+// subscriptions, the observer and the dead letters are kept. The previous bus
+// is stopped, so Configure should be called at program start before events
+// are emitted.
+func Configure(cfg Config) {
+	next := New(cfg)
+	prev := defaultBus.Load()
+	if prev != nil {
+		prev.mu.RLock()
+		for topic, subs := range prev.subs {
+			for _, sub := range subs {
+				// the workers of prev stop with it
+				sub.delivery = sub.delivery.restart()
+				next.subs[topic] = append(next.subs[topic], sub)
+				next.trie.insert(sub)
+			}
+		}
+		prev.mu.RUnlock()
+		prev.retryHandlers.Range(func(id, h any) bool {
+			next.retryHandlers.Store(id, h)
+			return true
+		})
+		next.Observe(prev.getObserver())
+	}
+	defaultBus.Store(next)
+	if prev != nil {
+		prev.Stop()
+		// handlers still running on prev may have dead-lettered until Stop
+		// returned
+		next.deadLetters.adopt(&prev.deadLetters)
 	}
 }
 
-var stream *eventStream
-
-type event struct {
-	topic   string
-	message any
+// Default returns the bus used by the package level functions.
+func Default() *Bus {
+	return defaultBus.Load()
 }
 
-// Subscription represents a handler subscribed to a specific topic.
-type Subscription struct {
-	ID        uint64
-	Topic     string
-	CreatedAt int64
-	Fn        HandlerFunc
+// Emit an event to the given topic. When the buffer is full the configured
+// overflow policy applies; events that cannot be queued are logged and
+// dropped.
+func Emit(topic string, event any) {
+	Default().Emit(topic, event)
 }
 
-type eventStream struct {
-	mu      sync.RWMutex
-	subs    map[string][]Subscription
-	eventch chan event
-
-	// context to cancel running handlers on stop
-	ctx    context.Context
-	cancel context.CancelFunc
-
-	// wait group to wait for handler goroutines spawned by the stream
-	wg sync.WaitGroup
-
-	// ensure stop is only performed once
-	stopOnce sync.Once
-
-	// indicator the stream has been stopped
-	closed atomic.Bool
+// EmitContext emits an event to the given topic and reports whether it was
+// queued. Under the Block policy it waits until there is room in the buffer
+// or ctx is done, in which case the context error is returned. Handlers
+// receive the values of ctx allowlisted with PropagateContext, but not its
+// cancellation.
+func EmitContext(ctx context.Context, topic string, event any) error {
+	return Default().EmitContext(ctx, topic, event)
 }
 
-// global counter for subscription IDs
-var subIDCounter atomic.Uint64
-
-func newStream() *eventStream {
-	ctx, cancel := context.WithCancel(context.Background())
-	e := &eventStream{
-		subs:    make(map[string][]Subscription),
-		eventch: make(chan event, 128),
-		ctx:     ctx,
-		cancel:  cancel,
-	}
-	go e.start()
-	return e
-}
-
-func (e *eventStream) start() {
-	for {
-		select {
-		case <-e.ctx.Done():
-			// context cancelled -> shutdown
-			return
-		case evt, ok := <-e.eventch:
-			if !ok {
-				return
-			}
-
-			// copy slice of handlers under read lock so we can iterate safely
-			e.mu.RLock()
-			handlers := append([]Subscription(nil), e.subs[evt.topic]...)
-			e.mu.RUnlock()
-
-			if len(handlers) == 0 {
-				continue
-			}
+// TryEmit emits an event to the given topic without ever blocking. It
+// returns ErrBufferFull if the event could not be queued.
+func TryEmit(topic string, event any) error {
+	return Default().TryEmit(topic, event)
+}
 
-			for _, sub := range handlers {
-				// run each handler in its own goroutine but track with WaitGroup
-				e.wg.Add(1)
-				go func(s Subscription, msg any) {
-					defer e.wg.Done()
-					// pass the stream context so handlers can observe cancellation
-					s.Fn(e.ctx, msg)
-				}(sub, evt.message)
-			}
-		}
-	}
+// EmitAndWait emits an event to the given topic and waits until every
+// handler subscribed to it has returned, or ctx is done.
+func EmitAndWait(ctx context.Context, topic string, event any) error {
+	return Default().EmitAndWait(ctx, topic, event)
 }
 
-func (e *eventStream) stop() {
-	e.stopOnce.Do(func() {
-		// mark closed so emits can be dropped
-		e.closed.Store(true)
+// Flush waits until all queued events have been handled and no handler is
+// running, or ctx is done.
+func Flush(ctx context.Context) error {
+	return Default().Flush(ctx)
+}
 
-		// cancel context to notify handlers
-		e.cancel()
+// Subscribe a HandlerFunc to the given topic.
+// A Subscription is returned that can be used to unsubscribe from the topic.
+//
+// The topic may be a pattern over its dot-separated segments: "*" matches
+// exactly one segment and ">" matches one or more trailing segments. For
+// example "auth.*" matches "auth.signup" and "auth.>" also matches
+// "auth.resend.verification". Subscribe panics if ">" is not the last
+// segment.
+//
+// By default every event is handled in its own goroutine; opts select
+// Serial, Workers or PartitionBy delivery instead.
+func Subscribe(topic string, h HandlerFunc, opts ...SubscribeOption) Subscription {
+	return Default().Subscribe(topic, h, opts...)
+}
 
-		// close event channel to stop the start loop
-		// it's safe to close here because stopOnce ensures this runs once
-		close(e.eventch)
+// Unsubscribe unsubscribes the given Subscription from its topic.
+func Unsubscribe(sub Subscription) {
+	Default().Unsubscribe(sub)
+}
 
-		// wait for in-flight handlers to finish
-		e.wg.Wait()
+// Observer is notified about the activity of a Bus. It lets packages such as
+// kit/metrics instrument the bus without the event
+// package depending on them. Implementations must be safe for concurrent use.
+type Observer interface {
+	// Emitted is called when an event was queued for delivery.
+	Emitted(topic string)
+	// Dropped is called when an event could not be queued.
+	Dropped(topic string)
+	// HandlerStarted is called before a handler is invoked.
+	HandlerStarted(topic string)
+	// HandlerFinished is called after a handler returned.
+	HandlerFinished(topic string, d time.Duration)
+}
 
-		// clear subscriptions
-		e.mu.Lock()
-		e.subs = make(map[string][]Subscription)
-		e.mu.Unlock()
-	})
+// Observe installs o as the observer of the default bus, replacing any
+// previously installed observer. Passing nil removes the observer.
+func Observe(o Observer) {
+	Default().Observe(o)
 }
 
-func (e *eventStream) emit(topic string, v any) {
-	// if the stream has been stopped, drop events
-	if e.closed.Load() {
-		slog.Debug("dropping event because stream is closed", "topic", topic)
-		return
-	}
+// ReadCounters returns the running totals of the default bus.
+func ReadCounters() Counters {
+	return Default().Counters()
+}
 
-	evt := event{
-		topic:   topic,
-		message: v,
-	}
+type topicKey struct{}
 
-	// Try to send without blocking; if the buffer is full, drop and log.
-	select {
-	case e.eventch <- evt:
-	default:
-		// channel full - avoid blocking producers
-		slog.Warn("event channel full; dropping event", "topic", topic)
-	}
+// TopicFromContext returns the topic of the event being handled. It lets
+// handlers subscribed to a wildcard pattern tell which topic matched.
+func TopicFromContext(ctx context.Context) string {
+	topic, _ := ctx.Value(topicKey{}).(string)
+	return topic
 }
 
-func (e *eventStream) subscribe(topic string, h HandlerFunc) Subscription {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+type busKey struct{}
 
-	sub := Subscription{
-		ID:        subIDCounter.Add(1),
-		CreatedAt: time.Now().UnixNano(),
-		Topic:     topic,
-		Fn:        h,
+// deliveringBus returns the bus delivering the event being handled, falling
+// back to b. Subscriptions move to a new bus on Configure, so handlers must
+// not hold on to the bus they were subscribed on.
+func deliveringBus(ctx context.Context, b *Bus) *Bus {
+	if delivering, ok := ctx.Value(busKey{}).(*Bus); ok {
+		return delivering
 	}
+	return b
+}
 
-	e.subs[topic] = append(e.subs[topic], sub)
-	return sub
+// Stop stops the default bus, waiting for in-flight handlers to complete.
+func Stop() {
+	Default().Stop()
 }
 
-func (e *eventStream) unsubscribe(sub Subscription) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+var defaultBus atomic.Pointer[Bus]
 
-	if _, ok := e.subs[sub.Topic]; ok {
-		e.subs[sub.Topic] = slices.DeleteFunc(e.subs[sub.Topic], func(s Subscription) bool {
-			return s.ID == sub.ID
-		})
-		if len(e.subs[sub.Topic]) == 0 {
-			delete(e.subs, sub.Topic)
-		}
-	}
+// Subscription represents a handler subscribed to a specific topic.
+type Subscription struct {
+	ID        uint64
+	Topic     string
+	CreatedAt int64
+	Fn        HandlerFunc
+
+	// workers handling the events of the subscription, nil if each event is
+	// handled in its own goroutine
+	delivery *delivery
+	// middleware wrapping Fn
+	middleware []Middleware
 }
 
 func init() {
-	stream = newStream()
+	defaultBus.Store(New(Config{}))
 }
@@ -2,8 +2,12 @@ package event
 
 import (
 	"context"
+	"errors"
 	"reflect"
+	"slices"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestEventSubscribeEmit(t *testing.T) {
@@ -24,9 +28,245 @@ func TestEventSubscribeEmit(t *testing.T) {
 }
 
 func TestUnsubscribe(t *testing.T) {
-	sub := Subscribe("foo.b", func(_ context.Context, _ any) {})
-	Unsubscribe(sub)
-	if _, ok := stream.subs["foo.b"]; ok {
-		t.Errorf("expected topic foo.bar to be deleted")
+	b := New(Config{})
+	defer b.Stop()
+
+	var calls atomic.Int32
+	sub := b.Subscribe("foo.b", func(context.Context, any) { calls.Add(1) })
+	if err := b.EmitAndWait(context.Background(), "foo.b", 1); err != nil {
+		t.Fatal(err)
+	}
+	b.Unsubscribe(sub)
+	if err := b.EmitAndWait(context.Background(), "foo.b", 2); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected handler to run once before unsubscribing, ran %d times", calls.Load())
+	}
+}
+
+func TestEmitAndWait(t *testing.T) {
+	b := New(Config{})
+	defer b.Stop()
+
+	var done atomic.Int32
+	for range 3 {
+		b.Subscribe("foo.wait", func(context.Context, any) {
+			time.Sleep(5 * time.Millisecond)
+			done.Add(1)
+		})
+	}
+	if err := b.EmitAndWait(context.Background(), "foo.wait", nil); err != nil {
+		t.Fatal(err)
+	}
+	if done.Load() != 3 {
+		t.Errorf("expected all 3 handlers to finish, got %d", done.Load())
+	}
+
+	b.Subscribe("foo.slow", func(ctx context.Context, _ any) { time.Sleep(50 * time.Millisecond) })
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	if err := b.EmitAndWait(ctx, "foo.slow", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded got %v", err)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	b := New(Config{})
+	defer b.Stop()
+
+	var done atomic.Int32
+	b.Subscribe("foo.flush.>", func(context.Context, any) {
+		time.Sleep(time.Millisecond)
+		done.Add(1)
+	}, Serial())
+	for i := range 20 {
+		b.Emit("foo.flush.a", i)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := b.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if done.Load() != 20 {
+		t.Errorf("expected 20 handled events after flush, got %d", done.Load())
+	}
+}
+
+// stalledStream returns a bus whose loop is not running, so emitted events
+// stay in the buffer.
+func stalledStream(cfg Config) *Bus {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Bus{
+		cfg:     cfg,
+		subs:    make(map[string][]Subscription),
+		eventch: make(chan event, cfg.BufferSize),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	s := stalledStream(Config{BufferSize: 1, Overflow: DropNewest})
+	if err := s.EmitContext(context.Background(), "a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EmitContext(context.Background(), "a", 2); !errors.Is(err, ErrBufferFull) {
+		t.Fatalf("expected ErrBufferFull got %v", err)
+	}
+	if evt := <-s.eventch; evt.message != 1 {
+		t.Errorf("expected 1 got %v", evt.message)
+	}
+	if s.queued.Load() != 1 || s.dropped.Load() != 1 {
+		t.Errorf("expected 1 queued and 1 dropped got %d and %d", s.queued.Load(), s.dropped.Load())
+	}
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	s := stalledStream(Config{BufferSize: 2, Overflow: DropOldest})
+	for i := 1; i <= 3; i++ {
+		if err := s.EmitContext(context.Background(), "a", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if evt := <-s.eventch; evt.message != 2 {
+		t.Errorf("expected 2 got %v", evt.message)
+	}
+	if evt := <-s.eventch; evt.message != 3 {
+		t.Errorf("expected 3 got %v", evt.message)
+	}
+	if s.dropped.Load() != 1 {
+		t.Errorf("expected 1 dropped got %d", s.dropped.Load())
+	}
+}
+
+func TestOverflowBlock(t *testing.T) {
+	s := stalledStream(Config{BufferSize: 1, Overflow: Block})
+	if err := s.EmitContext(context.Background(), "a", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := s.EmitContext(ctx, "a", 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded got %v", err)
 	}
+	if err := s.TryEmit("a", 2); !errors.Is(err, ErrBufferFull) {
+		t.Fatalf("expected TryEmit to fail with ErrBufferFull got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- s.EmitContext(context.Background(), "a", 3) }()
+	<-s.eventch
+	if err := <-done; err != nil {
+		t.Fatalf("expected blocked emit to succeed got %v", err)
+	}
+}
+
+func TestEmitAfterStop(t *testing.T) {
+	s := New(Config{BufferSize: 1, Overflow: Block})
+	s.Stop()
+	if err := s.EmitContext(context.Background(), "a", 1); !errors.Is(err, ErrStopped) {
+		t.Fatalf("expected ErrStopped got %v", err)
+	}
+}
+
+func TestTopic(t *testing.T) {
+	type signup struct{ Email string }
+	topic := NewTopic[signup]("foo.typed")
+
+	got := make(chan signup, 2)
+	sub := topic.Subscribe(func(_ context.Context, s signup) { got <- s })
+	defer Unsubscribe(sub)
+
+	if err := topic.Emit(context.Background(), signup{Email: "a@b.c"}); err != nil {
+		t.Fatal(err)
+	}
+	if s := <-got; s.Email != "a@b.c" {
+		t.Errorf("expected a@b.c got %q", s.Email)
+	}
+
+	// Mismatched payloads sent through the string API never reach the handler.
+	Emit(topic.Name(), "not a signup")
+	Emit(topic.Name(), signup{Email: "d@e.f"})
+	if s := <-got; s.Email != "d@e.f" {
+		t.Errorf("expected d@e.f got %q", s.Email)
+	}
+}
+
+func TestTopicTrieMatch(t *testing.T) {
+	trie := newTopicTrie()
+	patterns := []string{"auth.signup", "auth.*", "auth.>", "*.signup", ">", "billing.>"}
+	for i, p := range patterns {
+		trie.insert(Subscription{ID: uint64(i), Topic: p})
+	}
+
+	tests := map[string][]string{
+		"auth.signup":              {"auth.signup", "auth.*", "auth.>", "*.signup", ">"},
+		"auth.resend.verification": {"auth.>", ">"},
+		"auth":                     {">"},
+		"billing.invoice.paid":     {">", "billing.>"},
+	}
+	for topic, want := range tests {
+		var got []string
+		for _, sub := range trie.match(topic) {
+			got = append(got, sub.Topic)
+		}
+		slices.Sort(got)
+		slices.Sort(want)
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: expected %v got %v", topic, want, got)
+		}
+		for _, p := range patterns {
+			if matchPattern(p, topic) != slices.Contains(want, p) {
+				t.Errorf("%s: matchPattern(%q) disagrees with the trie", topic, p)
+			}
+		}
+	}
+
+	for i, p := range patterns {
+		trie.remove(Subscription{ID: uint64(i), Topic: p})
+	}
+	if len(trie.root.children) != 0 {
+		t.Errorf("expected empty trie after removing all subscriptions")
+	}
+}
+
+func TestWildcardSubscribe(t *testing.T) {
+	got := make(chan string, 2)
+	sub := Subscribe("foo.wild.>", func(_ context.Context, event any) {
+		got <- event.(string)
+	})
+	defer Unsubscribe(sub)
+
+	Emit("foo.wild.a", "a")
+	Emit("foo.wild.b.c", "b.c")
+	seen := []string{<-got, <-got}
+	slices.Sort(seen)
+	if !slices.Equal(seen, []string{"a", "b.c"}) {
+		t.Errorf("expected [a b.c] got %v", seen)
+	}
+}
+
+func TestSubscribeEmptySegment(t *testing.T) {
+	for _, topic := range []string{"foo..empty", "foo.empty."} {
+		got := make(chan any, 1)
+		sub := Subscribe(topic, func(_ context.Context, event any) {
+			got <- event
+		})
+		Emit(topic, topic)
+		if v := <-got; v != topic {
+			t.Errorf("expected %q got %v", topic, v)
+		}
+		Unsubscribe(sub)
+	}
+}
+
+func TestSubscribeInvalidPattern(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for misplaced >")
+		}
+	}()
+	Subscribe("foo.>.bar", func(context.Context, any) {})
 }
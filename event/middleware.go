@@ -0,0 +1,105 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// Middleware wraps a HandlerFunc. Middleware installed with Use wraps every
+// handler invocation; WithMiddleware wraps the handler of one subscription.
+// TopicFromContext returns the topic of the event inside middleware.
+type Middleware func(HandlerFunc) HandlerFunc
+
+var globalMiddleware atomic.Pointer[[]Middleware]
+
+// Use appends mw to the middleware wrapping every handler invocation,
+// including those of existing subscriptions. The first middleware is the
+// outermost.
+func Use(mw ...Middleware) {
+	for {
+		old := globalMiddleware.Load()
+		var next []Middleware
+		if old != nil {
+			next = slices.Clone(*old)
+		}
+		next = append(next, mw...)
+		if globalMiddleware.CompareAndSwap(old, &next) {
+			return
+		}
+	}
+}
+
+// chain wraps h with mw, the first middleware being the outermost.
+func chain(h HandlerFunc, mw []Middleware) HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// Recover recovers panics in handlers and logs them with the topic and the
+// stack trace, so a failing handler does not take down the process.
+func Recover() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event any) {
+			defer func() {
+				if r := recover(); r != nil {
+					markFailed(ctx)
+					slog.Error("event handler panicked",
+						"topic", TopicFromContext(ctx),
+						"panic", fmt.Sprint(r),
+						"stack", string(debug.Stack()))
+				}
+			}()
+			next(ctx, event)
+		}
+	}
+}
+
+// Logger logs every handled event with its topic and duration at debug
+// level. A nil logger uses the logger propagated from the emitter with
+// ContextWithLogger, or slog.Default.
+func Logger(logger *slog.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event any) {
+			l := logger
+			if l == nil {
+				l = LoggerFromContext(ctx)
+			}
+			start := time.Now()
+			next(ctx, event)
+			l.DebugContext(ctx, "event handled", "topic", TopicFromContext(ctx), "duration", time.Since(start))
+		}
+	}
+}
+
+// Measure calls record with the topic and duration of every handler
+// invocation, for example to feed a histogram.
+func Measure(record func(topic string, d time.Duration)) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event any) {
+			start := time.Now()
+			defer func() {
+				record(TopicFromContext(ctx), time.Since(start))
+			}()
+			next(ctx, event)
+		}
+	}
+}
+
+// Timeout cancels the context passed to handlers after d. Handlers must
+// observe ctx.Done for the timeout to take effect.
+func Timeout(d time.Duration) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, event any) {
+			ctx, cancel := context.WithTimeout(ctx, d)
+			defer cancel()
+			next(ctx, event)
+		}
+	}
+}
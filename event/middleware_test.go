@@ -0,0 +1,82 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMiddlewareOrder(t *testing.T) {
+	defer globalMiddleware.Store(nil)
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, event any) {
+				calls = append(calls, name+":"+TopicFromContext(ctx))
+				next(ctx, event)
+			}
+		}
+	}
+	Use(trace("global"))
+
+	s := New(Config{})
+	defer s.Stop()
+	sub := s.Subscribe("mw.order", func(context.Context, any) {
+		calls = append(calls, "handler")
+	}, WithMiddleware(trace("sub")))
+	s.invoke(context.Background(), sub, "mw.order", nil)
+
+	want := "global:mw.order sub:mw.order handler"
+	if got := strings.Join(calls, " "); got != want {
+		t.Errorf("expected %q got %q", want, got)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	defer globalMiddleware.Store(nil)
+	Use(Recover())
+
+	s := New(Config{})
+	sub := s.Subscribe("mw.panic", func(context.Context, any) { panic("boom") })
+	s.invoke(context.Background(), sub, "mw.panic", nil)
+
+	// the bus keeps running after a handler panicked
+	done := make(chan struct{})
+	s.Subscribe("mw.after", func(context.Context, any) { close(done) })
+	if err := s.EmitContext(context.Background(), "mw.panic", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.EmitContext(context.Background(), "mw.after", 1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected handler to run after panic")
+	}
+	s.Stop()
+}
+
+func TestTimeoutAndMeasure(t *testing.T) {
+	var measured string
+	h := chain(func(ctx context.Context, _ any) {
+		<-ctx.Done()
+		if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded got %v", ctx.Err())
+		}
+	}, []Middleware{
+		Measure(func(topic string, d time.Duration) {
+			if d >= 10*time.Millisecond {
+				measured = topic
+			}
+		}),
+		Timeout(10 * time.Millisecond),
+	})
+	h(context.WithValue(context.Background(), topicKey{}, "mw.timeout"), nil)
+	if measured != "mw.timeout" {
+		t.Errorf("expected duration to be measured for mw.timeout, got %q", measured)
+	}
+}
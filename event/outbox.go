@@ -0,0 +1,368 @@
+package event
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khulnasoft/superkit/db"
+)
+
+// OutboxTable is the name of the table the outbox is stored in.
+const OutboxTable = "event_outbox"
+
+// OutboxSchema creates the outbox table for sqlite. Applications normally
+// apply it through a migration; other databases need equivalent column types
+// and SetOutboxDriver.
+const OutboxSchema = `create table if not exists event_outbox(
+	id integer primary key,
+	topic text not null,
+	payload text not null,
+	attempts integer not null default 0,
+	last_error text,
+	created_at datetime not null,
+	available_at datetime not null,
+	locked_until datetime,
+	done_at datetime,
+	failed_at datetime
+);
+create index if not exists event_outbox_pending on event_outbox(done_at, failed_at, available_at);`
+
+// Execer executes statements. *sql.DB, *sql.Tx and gorm's ConnPool (for
+// example tx.Statement.ConnPool inside a gorm transaction) implement it.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// OutboxDB is the database the Outbox dispatcher reads from. *sql.DB and
+// gorm's ConnPool implement it.
+type OutboxDB interface {
+	Execer
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// outboxDriver is the database/sql driver name of the outbox database.
+var outboxDriver atomic.Value // string
+
+// SetOutboxDriver sets the database/sql driver name of the database the
+// outbox is stored in, such as "sqlite3", "mysql", "postgres" or "pgx",
+// which decides the placeholder style of EmitTx, EmitTxAt and CancelTx and
+// the default of OutboxConfig.Driver. Defaults to "sqlite3".
+func SetOutboxDriver(driver string) {
+	outboxDriver.Store(driver)
+}
+
+func currentOutboxDriver() string {
+	driver, _ := outboxDriver.Load().(string)
+	return driver
+}
+
+// EmitTx writes an event for topic to the outbox using tx, so the event is
+// stored if and only if the surrounding transaction commits. An Outbox
+// dispatcher delivers it to the subscribers afterwards, and to the peers of
+// a connected bus on the first attempt, see Bus.Connect.
+func EmitTx(ctx context.Context, tx Execer, topic string, event any) error {
+	_, err := insertOutbox(ctx, tx, time.Now(), topic, event, false)
+	return err
+}
+
+// rowQuerier is implemented by *sql.DB, *sql.Tx and gorm's ConnPool. Inserts
+// on postgres read the new ID through it, since postgres drivers do not
+// support LastInsertId.
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insertOutbox writes an event to the outbox that is due at the given time.
+// The ID of the new row is only returned if wantID is set.
+func insertOutbox(ctx context.Context, tx Execer, at time.Time, topic string, event any, wantID bool) (int64, error) {
+	payload, err := encodePayload(event)
+	if err != nil {
+		return 0, fmt.Errorf("event: encode payload for %s: %w", topic, err)
+	}
+	driver := currentOutboxDriver()
+	query := db.Rebind(driver,
+		"insert into "+OutboxTable+" (topic, payload, attempts, created_at, available_at) values (?, ?, 0, ?, ?)")
+	args := []any{topic, string(payload), time.Now().UTC(), at.UTC()}
+	if wantID && db.IsPostgres(driver) {
+		q, ok := tx.(rowQuerier)
+		if !ok {
+			return 0, fmt.Errorf("event: %T cannot return the ID of the outbox row on postgres", tx)
+		}
+		var id int64
+		err := q.QueryRowContext(ctx, query+" returning id", args...).Scan(&id)
+		return id, err
+	}
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil || !wantID {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// OutboxConfig configures an Outbox.
+type OutboxConfig struct {
+	// PollInterval is how often the outbox is checked for new events.
+	// Defaults to one second.
+	PollInterval time.Duration
+	// BatchSize is the maximum number of events delivered per poll.
+	// Defaults to 100.
+	BatchSize int
+	// MaxAttempts is how often delivery of an event is attempted before it
+	// is marked as failed. Defaults to 10.
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry. It doubles with
+	// every attempt up to one hour. Defaults to one second.
+	RetryBackoff time.Duration
+	// LockTimeout is how long an event is reserved for a dispatcher. If the
+	// dispatcher dies during delivery another one picks the event up after
+	// this timeout. Defaults to one minute.
+	LockTimeout time.Duration
+	// Driver is the database/sql driver name of the database, which decides
+	// the placeholder style. Defaults to the driver set with
+	// SetOutboxDriver.
+	Driver string
+	// Retention is how long delivered events are kept before Run deletes
+	// them. Failed events are kept for inspection. Defaults to 24 hours; a
+	// negative value keeps delivered events forever.
+	Retention time.Duration
+	// Bus delivers the events. Defaults to the default bus.
+	Bus *Bus
+}
+
+// Outbox delivers events written with EmitTx to the subscribers of their
+// topic. Delivery is at-least-once: an event is marked done only after all
+// handlers returned, so a crash during delivery causes it to be delivered
+// again. Handlers that panic, including panics recovered by Recover and
+// handlers whose Retry attempts are exhausted, count as a failed attempt and
+// the event is retried with exponential backoff. Events whose payload cannot
+// be decoded are marked as failed straight away.
+//
+// Several dispatchers may share an outbox table; each event is reserved by
+// one of them at a time.
+type Outbox struct {
+	db  OutboxDB
+	cfg OutboxConfig
+}
+
+// NewOutbox returns an Outbox dispatcher reading from db.
+func NewOutbox(db OutboxDB, cfg OutboxConfig) *Outbox {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = time.Second
+	}
+	if cfg.LockTimeout <= 0 {
+		cfg.LockTimeout = time.Minute
+	}
+	if cfg.Retention == 0 {
+		cfg.Retention = 24 * time.Hour
+	}
+	if cfg.Driver == "" {
+		cfg.Driver = currentOutboxDriver()
+	}
+	return &Outbox{db: db, cfg: cfg}
+}
+
+// Run delivers outbox events until ctx is cancelled. Once a minute it
+// deletes delivered events older than Config.Retention.
+func (o *Outbox) Run(ctx context.Context) error {
+	ticker := time.NewTicker(o.cfg.PollInterval)
+	defer ticker.Stop()
+	var purged time.Time
+	for {
+		if o.cfg.Retention > 0 && time.Since(purged) >= time.Minute {
+			purged = time.Now()
+			if _, err := o.Purge(ctx); err != nil && ctx.Err() == nil {
+				slog.Error("outbox purge failed", "err", err)
+			}
+		}
+		for {
+			n, err := o.Dispatch(ctx)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("outbox dispatch failed", "err", err)
+			}
+			// keep going while full batches are returned
+			if err != nil || n < o.cfg.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge deletes the events delivered longer than Config.Retention ago and
+// returns how many it deleted.
+func (o *Outbox) Purge(ctx context.Context) (int64, error) {
+	if o.cfg.Retention < 0 {
+		return 0, nil
+	}
+	res, err := o.db.ExecContext(ctx, db.Rebind(o.cfg.Driver,
+		"delete from "+OutboxTable+" where done_at is not null and done_at <= ?"),
+		time.Now().UTC().Add(-o.cfg.Retention))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+type outboxRow struct {
+	id       int64
+	topic    string
+	payload  string
+	attempts int
+}
+
+// Dispatch delivers one batch of due events and returns the number of events
+// it found.
+func (o *Outbox) Dispatch(ctx context.Context) (int, error) {
+	now := time.Now().UTC()
+	rows, err := o.db.QueryContext(ctx, db.Rebind(o.cfg.Driver,
+		"select id, topic, payload, attempts from "+OutboxTable+
+			" where done_at is null and failed_at is null and available_at <= ?"+
+			" and (locked_until is null or locked_until <= ?) order by id limit ?"),
+		now, now, o.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	var batch []outboxRow
+	for rows.Next() {
+		var row outboxRow
+		if err := rows.Scan(&row.id, &row.topic, &row.payload, &row.attempts); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		batch = append(batch, row)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, row := range batch {
+		if ctx.Err() != nil {
+			return len(batch), ctx.Err()
+		}
+		if err := o.process(ctx, row); err != nil {
+			return len(batch), err
+		}
+	}
+	return len(batch), nil
+}
+
+// process reserves row, delivers it and records the outcome.
+func (o *Outbox) process(ctx context.Context, row outboxRow) error {
+	now := time.Now().UTC()
+	res, err := o.db.ExecContext(ctx, db.Rebind(o.cfg.Driver,
+		"update "+OutboxTable+" set locked_until = ?, attempts = attempts + 1"+
+			" where id = ? and done_at is null and (locked_until is null or locked_until <= ?)"),
+		now.Add(o.cfg.LockTimeout), row.id, now)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		// another dispatcher reserved the event first
+		return err
+	}
+	row.attempts++
+
+	msg, err := decodePayload(row.topic, []byte(row.payload))
+	if err != nil {
+		// retrying will not make the payload decode
+		err = Permanent(fmt.Errorf("decode payload: %w", err))
+	} else {
+		bus := o.cfg.Bus
+		if bus == nil {
+			bus = Default()
+		}
+		if row.attempts == 1 {
+			// peers get the event once, like events emitted with Emit
+			bus.forward(row.topic, msg)
+		}
+		err = bus.deliver(ctx, row.topic, msg)
+	}
+	// Record the outcome even if ctx was cancelled during delivery.
+	ctx = context.WithoutCancel(ctx)
+	now = time.Now().UTC()
+	if err == nil {
+		_, err = o.db.ExecContext(ctx, db.Rebind(o.cfg.Driver,
+			"update "+OutboxTable+" set done_at = ?, locked_until = null, last_error = null where id = ?"),
+			now, row.id)
+		return err
+	}
+
+	slog.Error("outbox delivery failed", "topic", row.topic, "id", row.id, "attempt", row.attempts, "err", err)
+	if row.attempts >= o.cfg.MaxAttempts || IsPermanent(err) {
+		_, err = o.db.ExecContext(ctx, db.Rebind(o.cfg.Driver,
+			"update "+OutboxTable+" set failed_at = ?, locked_until = null, last_error = ? where id = ?"),
+			now, err.Error(), row.id)
+		return err
+	}
+	_, err = o.db.ExecContext(ctx, db.Rebind(o.cfg.Driver,
+		"update "+OutboxTable+" set available_at = ?, locked_until = null, last_error = ? where id = ?"),
+		now.Add(o.backoff(row.attempts)), err.Error(), row.id)
+	return err
+}
+
+// backoff returns the delay before the retry following the given attempt.
+func (o *Outbox) backoff(attempt int) time.Duration {
+	d := o.cfg.RetryBackoff
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, time.Hour)
+}
+
+// deliver runs all handlers subscribed to topic with msg and waits for them
+// to return. A handler that panics or is marked as failed by middleware is
+// reported as an error.
+func (b *Bus) deliver(ctx context.Context, topic string, msg any) error {
+	b.mu.RLock()
+	handlers := b.trie.match(topic)
+	b.mu.RUnlock()
+
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for _, sub := range handlers {
+		wg.Add(1)
+		b.dispatch(sub, msg, func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					slog.Error("event handler panicked", "topic", topic, "panic", r, "stack", string(debug.Stack()))
+					b.stats.failed(topic)
+					mu.Lock()
+					errs = append(errs, fmt.Errorf("handler for %s panicked: %v", sub.Topic, r))
+					mu.Unlock()
+				}
+			}()
+			if b.invoke(ctx, sub, topic, msg) {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("handler for %s failed", sub.Topic))
+				mu.Unlock()
+			}
+		})
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
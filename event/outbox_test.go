@@ -0,0 +1,230 @@
+package event
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type recordingExecer struct {
+	query  string
+	args   []any
+	result sql.Result
+}
+
+func (r *recordingExecer) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	r.query = query
+	r.args = args
+	return r.result, nil
+}
+
+// insertResult reports id as the last insert ID and one affected row.
+type insertResult int64
+
+func (r insertResult) LastInsertId() (int64, error) { return int64(r), nil }
+func (r insertResult) RowsAffected() (int64, error) { return 1, nil }
+
+func TestEmitTx(t *testing.T) {
+	type signup struct{ Email string }
+	topic := NewTopic[signup]("outbox.signup")
+
+	var tx recordingExecer
+	if err := topic.EmitTx(context.Background(), &tx, signup{Email: "a@b.c"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(tx.query, "insert into "+OutboxTable) {
+		t.Fatalf("unexpected query %q", tx.query)
+	}
+	if tx.args[0] != "outbox.signup" || tx.args[1] != `{"Email":"a@b.c"}` {
+		t.Fatalf("unexpected args %v", tx.args)
+	}
+
+	// The payload decodes into the type registered by NewTopic.
+	v, err := decodePayload("outbox.signup", []byte(tx.args[1].(string)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := v.(signup); !ok || s.Email != "a@b.c" {
+		t.Fatalf("expected signup{a@b.c} got %#v", v)
+	}
+}
+
+func TestEmitTxPostgres(t *testing.T) {
+	SetOutboxDriver("pgx")
+	t.Cleanup(func() { SetOutboxDriver("") })
+
+	tx := recordingExecer{result: insertResult(1)}
+	if err := EmitTx(context.Background(), &tx, "outbox.pg", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(tx.query, "values ($1, $2, 0, $3, $4)") {
+		t.Fatalf("expected postgres placeholders got %q", tx.query)
+	}
+	if _, err := CancelTx(context.Background(), &tx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(tx.query, "id = $1") || !strings.Contains(tx.query, "locked_until <= $2") {
+		t.Fatalf("expected postgres placeholders got %q", tx.query)
+	}
+}
+
+func TestDecodePayloadUnregistered(t *testing.T) {
+	v, err := decodePayload("outbox.unknown", []byte(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, ok := v.(map[string]any); !ok || m["a"] != float64(1) {
+		t.Fatalf("expected map got %#v", v)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	s := New(Config{})
+	defer s.Stop()
+
+	var ran bool
+	s.Subscribe("outbox.deliver", func(context.Context, any) { ran = true })
+	if err := s.deliver(context.Background(), "outbox.deliver", 1); err != nil {
+		t.Fatal(err)
+	}
+	if !ran {
+		t.Fatal("expected handler to run before deliver returned")
+	}
+
+	s.Subscribe("outbox.deliver", func(context.Context, any) { panic("boom") })
+	if err := s.deliver(context.Background(), "outbox.deliver", 1); err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("expected panic to be reported, got %v", err)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	o := NewOutbox(nil, OutboxConfig{RetryBackoff: time.Second})
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 20: time.Hour} {
+		if got := o.backoff(attempt); got != want {
+			t.Errorf("attempt %d: expected %s got %s", attempt, want, got)
+		}
+	}
+}
+
+func openOutbox(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "outbox.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(OutboxSchema); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestOutboxDispatch(t *testing.T) {
+	type signup struct{ Email string }
+	topic := NewTopic[signup]("outbox.dispatch")
+	db := openOutbox(t)
+	b := New(Config{})
+	defer b.Stop()
+	var got []signup
+	b.Subscribe(topic.Name(), func(_ context.Context, v any) { got = append(got, v.(signup)) })
+
+	ctx := context.Background()
+	if err := topic.EmitTx(ctx, db, signup{Email: "a@b.c"}); err != nil {
+		t.Fatal(err)
+	}
+	// a payload that does not decode into the topic's type
+	if _, err := db.Exec("insert into "+OutboxTable+" (topic, payload, attempts, created_at, available_at) values (?, ?, 0, ?, ?)",
+		topic.Name(), "{", time.Now().UTC(), time.Now().UTC()); err != nil {
+		t.Fatal(err)
+	}
+
+	o := NewOutbox(db, OutboxConfig{Bus: b, Retention: time.Hour})
+	if n, err := o.Dispatch(ctx); err != nil || n != 2 {
+		t.Fatalf("expected 2 events, got %d %v", n, err)
+	}
+	if len(got) != 1 || got[0].Email != "a@b.c" {
+		t.Fatalf("unexpected deliveries %+v", got)
+	}
+	var done, failed, attempts int
+	if err := db.QueryRow("select count(done_at), count(failed_at), sum(attempts) from "+OutboxTable).Scan(&done, &failed, &attempts); err != nil {
+		t.Fatal(err)
+	}
+	if done != 1 || failed != 1 || attempts != 2 {
+		t.Fatalf("expected one done and one failed event without retries, got %d done %d failed %d attempts", done, failed, attempts)
+	}
+
+	if n, err := o.Purge(ctx); err != nil || n != 0 {
+		t.Fatalf("expected recent events to be kept, purged %d %v", n, err)
+	}
+	o = NewOutbox(db, OutboxConfig{Bus: b, Retention: time.Nanosecond})
+	if n, err := o.Purge(ctx); err != nil || n != 1 {
+		t.Fatalf("expected the delivered event to be purged, purged %d %v", n, err)
+	}
+}
+
+func TestOutboxRecover(t *testing.T) {
+	defer globalMiddleware.Store(nil)
+	Use(Recover())
+
+	db := openOutbox(t)
+	b := New(Config{})
+	defer b.Stop()
+	b.Subscribe("outbox.recover", func(context.Context, any) { panic("boom") })
+
+	ctx := context.Background()
+	if err := EmitTx(ctx, db, "outbox.recover", "x"); err != nil {
+		t.Fatal(err)
+	}
+	o := NewOutbox(db, OutboxConfig{Bus: b, MaxAttempts: 2, RetryBackoff: time.Nanosecond})
+	for range 2 {
+		if _, err := o.Dispatch(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the panic recovered by Recover counts as a failed attempt
+	var done, failed, attempts int
+	if err := db.QueryRow("select count(done_at), count(failed_at), sum(attempts) from "+OutboxTable).Scan(&done, &failed, &attempts); err != nil {
+		t.Fatal(err)
+	}
+	if done != 0 || failed != 1 || attempts != 2 {
+		t.Fatalf("expected the event to fail after 2 attempts, got %d done %d failed %d attempts", done, failed, attempts)
+	}
+}
+
+func TestOutboxForwardsToPeers(t *testing.T) {
+	db := openOutbox(t)
+	a, ta := connectPeer(t, PeerConfig{Listen: "127.0.0.1:0"})
+	b, _ := connectPeer(t, PeerConfig{Peers: []string{ta.Addr().String()}})
+	got := make(chan any, 16)
+	b.Subscribe("outbox.peer", func(_ context.Context, msg any) {
+		got <- msg
+	})
+
+	// the subscription reaches a asynchronously; emit until it arrives
+	ctx := context.Background()
+	o := NewOutbox(db, OutboxConfig{Bus: a})
+	deadline := time.After(5 * time.Second)
+	for {
+		if err := EmitTx(ctx, db, "outbox.peer", "hi"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := o.Dispatch(ctx); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case v := <-got:
+			if v != "hi" {
+				t.Fatalf("expected hi got %v", v)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("outbox event not forwarded to the peer")
+		}
+	}
+}
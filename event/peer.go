@@ -0,0 +1,573 @@
+package event
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PeerConfig configures a PeerTransport.
+type PeerConfig struct {
+	// Network is "tcp" or "unix". Defaults to "tcp".
+	Network string
+	// Listen is the address other instances connect to, for example
+	// ":7070" or a socket path. Leave empty to only dial peers.
+	Listen string
+	// Peers are the addresses of the other instances. Every instance may
+	// list every other instance; duplicate connections are closed.
+	Peers []string
+	// Codec encodes payloads. Defaults to JSON.
+	Codec Codec
+	// ReconnectMin and ReconnectMax bound the exponential backoff between
+	// attempts to reconnect to a peer. Default to 100ms and 10 seconds.
+	ReconnectMin time.Duration
+	ReconnectMax time.Duration
+	// QueueSize is the number of frames buffered per peer before events to
+	// it are dropped. Defaults to 1024.
+	QueueSize int
+	// DialTimeout bounds connecting to a peer and the handshake. Defaults
+	// to 5 seconds.
+	DialTimeout time.Duration
+	// Secret is shared by all instances. When set, both ends of every
+	// connection prove they know it in the handshake, and connections from
+	// instances that do not are closed. The events themselves are not
+	// encrypted; use TLS for that.
+	Secret string
+	// TLS, when set, is used to listen and to dial peers. Set ClientCAs and
+	// ClientAuth to also authenticate the dialing instances. ServerName
+	// defaults to the host of the peer address.
+	TLS *tls.Config
+}
+
+// PeerTransport connects instances of an application directly over TCP or
+// Unix sockets. Every instance tells its peers which topic patterns it has
+// subscribers for, and only matching events are sent to it. Lost
+// connections are reestablished with exponential backoff; events published
+// while a peer is disconnected are not delivered to it.
+//
+// Anyone who can connect to the listening address can publish events to
+// the instance, so listening on a TCP address other than loopback requires
+// a Secret or TLS.
+//
+//	t := event.NewPeerTransport(event.PeerConfig{
+//		Listen: ":7070",
+//		Peers:  []string{"10.0.0.2:7070", "10.0.0.3:7070"},
+//		Secret: os.Getenv("EVENT_PEER_SECRET"),
+//	})
+//	if err := event.Connect(t); err != nil {
+//		log.Fatal(err)
+//	}
+type PeerTransport struct {
+	cfg PeerConfig
+	// id identifies the instance to its peers
+	id string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	ln      net.Listener
+	deliver func(topic string, payload any)
+
+	mu       sync.Mutex
+	patterns []string
+	conns    map[string]*peerConn // by peer ID
+	accepted map[net.Conn]struct{}
+}
+
+// NewPeerTransport returns a PeerTransport configured by cfg. It starts
+// listening and dialing when the bus connects through it.
+func NewPeerTransport(cfg PeerConfig) *PeerTransport {
+	if cfg.Network == "" {
+		cfg.Network = "tcp"
+	}
+	if cfg.Codec == nil {
+		cfg.Codec = JSON
+	}
+	if cfg.ReconnectMin <= 0 {
+		cfg.ReconnectMin = 100 * time.Millisecond
+	}
+	if cfg.ReconnectMax <= 0 {
+		cfg.ReconnectMax = 10 * time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1024
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = 5 * time.Second
+	}
+	var id [8]byte
+	rand.Read(id[:])
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PeerTransport{
+		cfg:      cfg,
+		id:       hex.EncodeToString(id[:]),
+		ctx:      ctx,
+		cancel:   cancel,
+		conns:    make(map[string]*peerConn),
+		accepted: make(map[net.Conn]struct{}),
+	}
+}
+
+// Addr returns the address the transport listens on, or nil if it does not
+// listen. It is useful when listening on port 0.
+func (t *PeerTransport) Addr() net.Addr {
+	if t.ln == nil {
+		return nil
+	}
+	return t.ln.Addr()
+}
+
+// Start listens on the configured address and starts dialing the peers.
+func (t *PeerTransport) Start(deliver func(topic string, payload any)) error {
+	t.deliver = deliver
+	if t.cfg.Listen != "" {
+		if t.cfg.Secret == "" && t.cfg.TLS == nil && !isLoopback(t.cfg.Network, t.cfg.Listen) {
+			return fmt.Errorf("event: refusing to listen for peers on %s without a Secret or TLS", t.cfg.Listen)
+		}
+		ln, err := net.Listen(t.cfg.Network, t.cfg.Listen)
+		if err != nil {
+			return fmt.Errorf("event: listen for peers: %w", err)
+		}
+		if t.cfg.TLS != nil {
+			ln = tls.NewListener(ln, t.cfg.TLS)
+		}
+		t.ln = ln
+		t.wg.Add(1)
+		go t.accept()
+	}
+	for _, addr := range t.cfg.Peers {
+		t.wg.Add(1)
+		go t.dial(addr)
+	}
+	return nil
+}
+
+// Publish queues the event for every connected peer subscribed to topic.
+// It returns an error if the queue of a peer is full.
+func (t *PeerTransport) Publish(topic string, payload any) error {
+	t.mu.Lock()
+	var targets []*peerConn
+	for _, pc := range t.conns {
+		if pc.subscribed(topic) {
+			targets = append(targets, pc)
+		}
+	}
+	t.mu.Unlock()
+	if len(targets) == 0 {
+		return nil
+	}
+
+	data, err := t.cfg.Codec.Marshal(topic, payload)
+	if err != nil {
+		return fmt.Errorf("event: encode payload: %w", err)
+	}
+	var errs []error
+	for _, pc := range targets {
+		if !pc.send(frame{kind: frameEvent, topic: topic, data: data}) {
+			errs = append(errs, fmt.Errorf("event: queue of peer %s is full", pc.conn.RemoteAddr()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Register sends the patterns to all connected peers and to peers
+// connecting later.
+func (t *PeerTransport) Register(patterns []string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.patterns = patterns
+	for _, pc := range t.conns {
+		pc.send(subscribeFrame(patterns))
+	}
+	return nil
+}
+
+// Close closes the listener and all connections and waits for them to shut
+// down.
+func (t *PeerTransport) Close() error {
+	t.cancel()
+	var err error
+	if t.ln != nil {
+		err = t.ln.Close()
+	}
+	t.mu.Lock()
+	for _, pc := range t.conns {
+		pc.close()
+	}
+	for conn := range t.accepted {
+		conn.Close()
+	}
+	t.mu.Unlock()
+	t.wg.Wait()
+	return err
+}
+
+func (t *PeerTransport) accept() {
+	defer t.wg.Done()
+	for {
+		conn, err := t.ln.Accept()
+		if err != nil {
+			if t.ctx.Err() == nil {
+				slog.Error("accepting event peer", "err", err)
+			}
+			return
+		}
+		t.mu.Lock()
+		if t.ctx.Err() != nil {
+			t.mu.Unlock()
+			conn.Close()
+			return
+		}
+		t.accepted[conn] = struct{}{}
+		t.mu.Unlock()
+
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			t.serve(conn, false)
+			t.mu.Lock()
+			delete(t.accepted, conn)
+			t.mu.Unlock()
+		}()
+	}
+}
+
+// dial keeps a connection to the peer at addr until the transport is closed.
+func (t *PeerTransport) dial(addr string) {
+	defer t.wg.Done()
+	backoff := t.cfg.ReconnectMin
+	for {
+		conn, err := t.dialConn(addr)
+		if err == nil {
+			if done := t.serve(conn, true); done != nil {
+				// Connected, or already connected through the connection
+				// the peer dialed: reconnect once it is closed.
+				select {
+				case <-t.ctx.Done():
+				case <-done:
+				}
+				backoff = t.cfg.ReconnectMin
+			}
+		} else if t.ctx.Err() == nil {
+			slog.Debug("dialing event peer", "addr", addr, "err", err)
+		}
+
+		select {
+		case <-t.ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, t.cfg.ReconnectMax)
+	}
+}
+
+// dialConn connects to the peer at addr, over TLS if configured.
+func (t *PeerTransport) dialConn(addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: t.cfg.DialTimeout}
+	if t.cfg.TLS == nil {
+		return dialer.DialContext(t.ctx, t.cfg.Network, addr)
+	}
+	cfg := t.cfg.TLS
+	if cfg.ServerName == "" {
+		cfg = cfg.Clone()
+		cfg.ServerName = addr
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			cfg.ServerName = host
+		}
+	}
+	tlsDialer := tls.Dialer{NetDialer: dialer, Config: cfg}
+	return tlsDialer.DialContext(t.ctx, t.cfg.Network, addr)
+}
+
+// serve runs the connection until it is closed. It returns a channel that is
+// closed when the connection to the peer is gone, or nil if the handshake
+// failed. If the peer is already connected through another connection, serve
+// closes conn and returns the channel of the other connection.
+func (t *PeerTransport) serve(conn net.Conn, dialed bool) <-chan struct{} {
+	defer conn.Close()
+
+	// Stop reading and writing when the transport is closed.
+	stop := context.AfterFunc(t.ctx, func() { conn.Close() })
+	defer stop()
+
+	r := bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(t.cfg.DialTimeout))
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	if err := writeFrame(conn, frame{kind: frameHello, topic: t.id, data: nonce[:]}); err != nil {
+		return nil
+	}
+	hello, err := readFrame(r)
+	if err != nil || hello.kind != frameHello {
+		slog.Debug("event peer handshake failed", "addr", conn.RemoteAddr(), "err", err)
+		return nil
+	}
+	if t.cfg.Secret != "" {
+		if err := writeFrame(conn, frame{kind: frameAuth, data: t.proof(dialed, hello.data, nonce[:])}); err != nil {
+			return nil
+		}
+		auth, err := readFrame(r)
+		if err != nil || auth.kind != frameAuth || len(hello.data) != len(nonce) ||
+			!hmac.Equal(auth.data, t.proof(!dialed, nonce[:], hello.data)) {
+			slog.Warn("event peer failed to authenticate", "addr", conn.RemoteAddr(), "err", err)
+			return nil
+		}
+	}
+	conn.SetDeadline(time.Time{})
+	if hello.topic == t.id {
+		// connected to ourselves
+		return nil
+	}
+
+	pc := &peerConn{
+		conn: conn,
+		out:  make(chan frame, t.cfg.QueueSize),
+		done: make(chan struct{}),
+	}
+	if current := t.add(hello.topic, pc, dialed); current != pc {
+		return current.done
+	}
+	defer t.remove(hello.topic, pc)
+
+	go pc.write()
+	for {
+		f, err := readFrame(r)
+		if err != nil {
+			if t.ctx.Err() == nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				slog.Warn("reading from event peer", "addr", conn.RemoteAddr(), "err", err)
+			}
+			return pc.done
+		}
+		switch f.kind {
+		case frameSubscribe:
+			pc.setPatterns(f.data)
+		case frameEvent:
+			payload, err := t.cfg.Codec.Unmarshal(f.topic, f.data)
+			if err != nil {
+				slog.Warn("decoding event from peer", "topic", f.topic, "err", err)
+				continue
+			}
+			t.deliver(f.topic, payload)
+		}
+	}
+}
+
+// proof returns the HMAC with which the dialing or the accepting end of a
+// connection proves it knows the secret. It covers the nonce of the other
+// end, so it cannot be replayed, and the role, so it cannot be reflected.
+func (t *PeerTransport) proof(dialed bool, theirNonce, ourNonce []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(t.cfg.Secret))
+	if dialed {
+		mac.Write([]byte("dial"))
+	} else {
+		mac.Write([]byte("accept"))
+	}
+	mac.Write(theirNonce)
+	mac.Write(ourNonce)
+	return mac.Sum(nil)
+}
+
+// isLoopback reports whether addr can only be reached from the local host.
+func isLoopback(network, addr string) bool {
+	if network == "unix" || network == "unixpacket" {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// add registers pc as the connection to the peer with the given ID and
+// sends it the local patterns. If both instances dialed each other, the
+// connection dialed by the instance with the lower ID is kept. add returns
+// the connection in use, which is not pc if pc is a duplicate.
+func (t *PeerTransport) add(id string, pc *peerConn, dialed bool) *peerConn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ctx.Err() != nil {
+		pc.close()
+		return pc
+	}
+	if prev, ok := t.conns[id]; ok {
+		if dialed != (t.id < id) {
+			return prev
+		}
+		prev.close()
+	}
+	t.conns[id] = pc
+	pc.send(subscribeFrame(t.patterns))
+	return pc
+}
+
+func (t *PeerTransport) remove(id string, pc *peerConn) {
+	t.mu.Lock()
+	if t.conns[id] == pc {
+		delete(t.conns, id)
+	}
+	t.mu.Unlock()
+	pc.close()
+}
+
+// peerConn is an established connection to a peer.
+type peerConn struct {
+	conn net.Conn
+	out  chan frame
+
+	mu       sync.RWMutex
+	patterns []string // subscribed by the peer
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func (pc *peerConn) subscribed(topic string) bool {
+	pc.mu.RLock()
+	defer pc.mu.RUnlock()
+	for _, p := range pc.patterns {
+		if matchPattern(p, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+func (pc *peerConn) setPatterns(data []byte) {
+	var patterns []string
+	if len(data) > 0 {
+		patterns = strings.Split(string(data), "\n")
+	}
+	pc.mu.Lock()
+	pc.patterns = patterns
+	pc.mu.Unlock()
+}
+
+// send queues f without blocking and reports whether it was queued.
+func (pc *peerConn) send(f frame) bool {
+	select {
+	case <-pc.done:
+		return true
+	default:
+	}
+	select {
+	case pc.out <- f:
+		return true
+	default:
+		return false
+	}
+}
+
+func (pc *peerConn) write() {
+	w := bufio.NewWriter(pc.conn)
+	for {
+		select {
+		case <-pc.done:
+			return
+		case f := <-pc.out:
+			if err := writeFrame(w, f); err != nil {
+				pc.close()
+				return
+			}
+			// batch queued frames into one write
+			if len(pc.out) == 0 {
+				if err := w.Flush(); err != nil {
+					pc.close()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (pc *peerConn) close() {
+	pc.closeOnce.Do(func() {
+		close(pc.done)
+		pc.conn.Close()
+	})
+}
+
+// Frames are length prefixed: a kind byte followed by the topic and the data,
+// each preceded by its length as a uvarint.
+type frame struct {
+	kind  byte
+	topic string
+	data  []byte
+}
+
+const (
+	// frameHello carries the instance ID in topic and a random nonce in
+	// data.
+	frameHello byte = iota + 1
+	// frameSubscribe carries the newline separated topic patterns.
+	frameSubscribe
+	// frameEvent carries an encoded payload.
+	frameEvent
+	// frameAuth carries the proof of the secret, see PeerTransport.proof.
+	frameAuth
+)
+
+// maxFrameSize bounds the topic and data of frames read from peers.
+const maxFrameSize = 16 << 20
+
+func subscribeFrame(patterns []string) frame {
+	return frame{kind: frameSubscribe, data: []byte(strings.Join(patterns, "\n"))}
+}
+
+func writeFrame(w io.Writer, f frame) error {
+	buf := make([]byte, 0, 1+2*binary.MaxVarintLen64+len(f.topic)+len(f.data))
+	buf = append(buf, f.kind)
+	buf = binary.AppendUvarint(buf, uint64(len(f.topic)))
+	buf = append(buf, f.topic...)
+	buf = binary.AppendUvarint(buf, uint64(len(f.data)))
+	buf = append(buf, f.data...)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readFrame(r *bufio.Reader) (frame, error) {
+	var f frame
+	kind, err := r.ReadByte()
+	if err != nil {
+		return f, err
+	}
+	f.kind = kind
+	topic, err := readChunk(r)
+	if err != nil {
+		return f, err
+	}
+	f.topic = string(topic)
+	f.data, err = readChunk(r)
+	return f, err
+}
+
+func readChunk(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > maxFrameSize {
+		return nil, fmt.Errorf("event: frame of %d bytes exceeds limit", n)
+	}
+	buf := make([]byte, n)
+	_, err = io.ReadFull(r, buf)
+	return buf, err
+}
@@ -0,0 +1,272 @@
+package event
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type peerGreeting struct {
+	Name  string
+	Count int
+}
+
+// connectPeer returns a bus connected through a PeerTransport configured by
+// cfg.
+func connectPeer(t *testing.T, cfg PeerConfig) (*Bus, *PeerTransport) {
+	t.Helper()
+	b := New(Config{})
+	t.Cleanup(b.Stop)
+	cfg.ReconnectMax = 200 * time.Millisecond
+	tr := NewPeerTransport(cfg)
+	if err := b.Connect(tr); err != nil {
+		t.Fatal(err)
+	}
+	return b, tr
+}
+
+// emitUntil emits payload on topic from b until got receives a value. The
+// subscriptions of the peers reach b asynchronously.
+func emitUntil[T any](t *testing.T, b *Bus, topic string, payload any, got <-chan T) T {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		if err := b.EmitContext(context.Background(), topic, payload); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case v := <-got:
+			return v
+		case <-tick.C:
+		case <-deadline:
+			t.Fatalf("no event received on %s", topic)
+		}
+	}
+}
+
+func TestPeerTransportTCP(t *testing.T) {
+	RegisterType("peer.greeting", peerGreeting{})
+
+	a, ta := connectPeer(t, PeerConfig{Listen: "127.0.0.1:0"})
+	b, _ := connectPeer(t, PeerConfig{Peers: []string{ta.Addr().String()}})
+
+	got := make(chan any, 16)
+	b.Subscribe("peer.*", func(_ context.Context, msg any) {
+		got <- msg
+	})
+
+	want := peerGreeting{Name: "bob", Count: 2}
+	if v := emitUntil(t, a, "peer.greeting", want, got); v != want {
+		t.Fatalf("expected %+v got %#v", want, v)
+	}
+
+	// events received from a peer are not sent back
+	local := make(chan any, 16)
+	a.Subscribe("peer.echo", func(_ context.Context, msg any) {
+		local <- msg
+	})
+	emitUntil(t, b, "peer.echo", "hi", local)
+	time.Sleep(50 * time.Millisecond)
+	for len(got) > 0 {
+		<-got
+	}
+	b.Emit("peer.echo", "hi")
+	<-local
+	time.Sleep(50 * time.Millisecond)
+	if len(got) != 1 {
+		t.Fatalf("expected the local delivery only, got %d", len(got))
+	}
+}
+
+func TestPeerTransportSecret(t *testing.T) {
+	a, ta := connectPeer(t, PeerConfig{Listen: "127.0.0.1:0", Secret: "s3cret"})
+	b, _ := connectPeer(t, PeerConfig{Peers: []string{ta.Addr().String()}, Secret: "s3cret"})
+	got := make(chan any, 16)
+	b.Subscribe("peer.secret", func(_ context.Context, msg any) {
+		got <- msg
+	})
+	emitUntil(t, a, "peer.secret", "hi", got)
+
+	// instances with another secret or none are not connected
+	for _, secret := range []string{"wrong", ""} {
+		c, _ := connectPeer(t, PeerConfig{Peers: []string{ta.Addr().String()}, Secret: secret})
+		c.Subscribe("peer.secret", func(_ context.Context, msg any) {
+			t.Errorf("event delivered to a peer with secret %q", secret)
+		})
+	}
+	time.Sleep(200 * time.Millisecond)
+	a.Emit("peer.secret", "hi")
+	<-got
+	time.Sleep(100 * time.Millisecond)
+}
+
+func TestPeerTransportTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	a, ta := connectPeer(t, PeerConfig{Listen: "127.0.0.1:0", TLS: server})
+	b, _ := connectPeer(t, PeerConfig{Peers: []string{ta.Addr().String()}, TLS: &tls.Config{RootCAs: pool}})
+	got := make(chan any, 16)
+	b.Subscribe("peer.tls", func(_ context.Context, msg any) {
+		got <- msg
+	})
+	emitUntil(t, a, "peer.tls", "hi", got)
+}
+
+func TestPeerTransportListenRequiresAuth(t *testing.T) {
+	b := New(Config{})
+	defer b.Stop()
+	if err := b.Connect(NewPeerTransport(PeerConfig{Listen: ":0"})); err == nil {
+		t.Fatal("expected listening on all interfaces without a secret to fail")
+	}
+	if err := b.Connect(NewPeerTransport(PeerConfig{Listen: ":0", Secret: "s3cret"})); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPeerTransportUnixGob(t *testing.T) {
+	RegisterType("peer.gob", peerGreeting{})
+
+	dir := t.TempDir()
+	sockA := filepath.Join(dir, "a.sock")
+	sockB := filepath.Join(dir, "b.sock")
+	// both instances dial each other; only one connection is kept
+	a, _ := connectPeer(t, PeerConfig{Network: "unix", Listen: sockA, Peers: []string{sockB}, Codec: Gob})
+	b, _ := connectPeer(t, PeerConfig{Network: "unix", Listen: sockB, Peers: []string{sockA}, Codec: Gob})
+
+	got := make(chan any, 16)
+	b.Subscribe("peer.gob", func(_ context.Context, msg any) {
+		got <- msg
+	})
+	want := peerGreeting{Name: "alice", Count: 1}
+	emitUntil(t, a, "peer.gob", want, got)
+
+	// drain duplicates of the retries, then expect exactly one delivery
+	time.Sleep(100 * time.Millisecond)
+	for len(got) > 0 {
+		<-got
+	}
+	a.Emit("peer.gob", want)
+	select {
+	case v := <-got:
+		if v != want {
+			t.Fatalf("expected %+v got %#v", want, v)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	time.Sleep(100 * time.Millisecond)
+	if len(got) != 0 {
+		t.Fatalf("expected one delivery, got %d more", len(got))
+	}
+}
+
+func TestPeerTransportReconnect(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "a.sock")
+
+	a := New(Config{})
+	if err := a.Connect(NewPeerTransport(PeerConfig{Network: "unix", Listen: sock})); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := connectPeer(t, PeerConfig{Network: "unix", Peers: []string{sock}})
+	got := make(chan any, 16)
+	b.Subscribe("peer.reconnect", func(_ context.Context, msg any) {
+		got <- msg
+	})
+	emitUntil(t, a, "peer.reconnect", "first", got)
+
+	// restart the listening instance
+	a.Stop()
+	a, _ = connectPeer(t, PeerConfig{Network: "unix", Listen: sock})
+	emitUntil(t, a, "peer.reconnect", "second", got)
+}
+
+// TestPeerHelperProcess is run as a separate process by
+// TestPeerTransportProcesses. It answers every ping with its process ID.
+func TestPeerHelperProcess(t *testing.T) {
+	addr := os.Getenv("EVENT_PEER_ADDR")
+	if addr == "" {
+		t.Skip("helper process")
+	}
+	b, _ := connectPeer(t, PeerConfig{Peers: []string{addr}})
+	done := make(chan struct{})
+	b.Subscribe("peer.ping", func(_ context.Context, msg any) {
+		if msg == "bye" {
+			close(done)
+			return
+		}
+		b.Emit("peer.pong", os.Getpid())
+	})
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no bye received")
+	}
+}
+
+func TestPeerTransportProcesses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spawns processes")
+	}
+	RegisterType("peer.pong", 0)
+
+	a, ta := connectPeer(t, PeerConfig{Listen: "127.0.0.1:0"})
+	got := make(chan int, 64)
+	a.Subscribe("peer.pong", func(_ context.Context, msg any) {
+		got <- msg.(int)
+	})
+
+	var cmds []*exec.Cmd
+	for range 2 {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestPeerHelperProcess$")
+		cmd.Env = append(os.Environ(), "EVENT_PEER_ADDR="+ta.Addr().String())
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		cmds = append(cmds, cmd)
+	}
+
+	// ping until both processes answered
+	pids := make(map[int]bool)
+	for len(pids) < len(cmds) {
+		pids[emitUntil(t, a, "peer.ping", "hello", got)] = true
+	}
+	a.Emit("peer.ping", "bye")
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("helper process: %v", err)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNoResponder is returned by Request when no responder is registered for
+// the topic.
+var ErrNoResponder = errors.New("event: no responder")
+
+// requestTopicPrefix namespaces the topics responders subscribe to, so
+// requests never reach ordinary subscribers of the same topic. topicTrie.match
+// keeps wildcard subscriptions such as ">" from matching them, and transports
+// do not forward them.
+const requestTopicPrefix = "_request."
+
+// ResponderFunc handles a request and returns the reply.
+type ResponderFunc func(ctx context.Context, req any) (any, error)
+
+type requestEnvelope struct {
+	id      uint64
+	payload any
+}
+
+type reply struct {
+	value any
+	err   error
+}
+
+// replies correlates requests with their replies by reply ID.
+type replies struct {
+	nextID  atomic.Uint64
+	mu      sync.Mutex
+	pending map[uint64]chan reply
+}
+
+func (r *replies) register() (uint64, chan reply) {
+	id := r.nextID.Add(1)
+	ch := make(chan reply, 1)
+	r.mu.Lock()
+	if r.pending == nil {
+		r.pending = make(map[uint64]chan reply)
+	}
+	r.pending[id] = ch
+	r.mu.Unlock()
+	return id, ch
+}
+
+func (r *replies) cancel(id uint64) {
+	r.mu.Lock()
+	delete(r.pending, id)
+	r.mu.Unlock()
+}
+
+// resolve delivers the reply for id. Only the first reply is kept; replies
+// to requests that timed out are discarded.
+func (r *replies) resolve(id uint64, rep reply) {
+	r.mu.Lock()
+	ch, ok := r.pending[id]
+	delete(r.pending, id)
+	r.mu.Unlock()
+	if ok {
+		ch <- rep
+	}
+}
+
+// Request sends a request to the responder of topic on the default bus and
+// waits for its reply. See Bus.Request.
+func Request(ctx context.Context, topic string, payload any) (any, error) {
+	return Default().Request(ctx, topic, payload)
+}
+
+// Respond registers fn as a responder for requests on topic on the default
+// bus. See Bus.Respond.
+func Respond(topic string, fn ResponderFunc, opts ...SubscribeOption) Subscription {
+	return Default().Respond(topic, fn, opts...)
+}
+
+// Request sends a request to the responder of topic and waits for its reply.
+// It fails with ErrNoResponder straight away if no responder is registered,
+// and with the context error once ctx is done. If ctx has no deadline,
+// Config.RequestTimeout applies. An error returned by the responder is
+// returned as is.
+func (b *Bus) Request(ctx context.Context, topic string, payload any) (any, error) {
+	requestTopic := requestTopicPrefix + topic
+	b.mu.RLock()
+	handled := len(b.trie.match(requestTopic)) > 0
+	b.mu.RUnlock()
+	if !handled {
+		return nil, fmt.Errorf("%w for %s", ErrNoResponder, topic)
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.cfg.RequestTimeout)
+		defer cancel()
+	}
+
+	id, ch := b.replies.register()
+	defer b.replies.cancel(id)
+	evt := newEvent(requestTopic, requestEnvelope{id: id, payload: payload})
+	evt.ctx = ctx
+	if err := b.emit(ctx, evt, true); err != nil {
+		return nil, err
+	}
+	select {
+	case rep := <-ch:
+		return rep.value, rep.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("event: request %s: %w", topic, ctx.Err())
+	}
+}
+
+// Respond registers fn as a responder for requests on topic. If several
+// responders are registered for a topic, the first reply wins. A panic in fn
+// is returned to the requester as an error.
+func (b *Bus) Respond(topic string, fn ResponderFunc, opts ...SubscribeOption) Subscription {
+	return b.Subscribe(requestTopicPrefix+topic, func(ctx context.Context, msg any) {
+		env, ok := msg.(requestEnvelope)
+		if !ok {
+			return
+		}
+		var rep reply
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					rep = reply{err: fmt.Errorf("event: responder for %s panicked: %v", topic, r)}
+				}
+			}()
+			rep.value, rep.err = fn(ctx, env.payload)
+		}()
+		// the request was registered with the bus delivering it
+		deliveringBus(ctx, b).replies.resolve(env.id, rep)
+	}, opts...)
+}
@@ -0,0 +1,99 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRequestReply(t *testing.T) {
+	b := New(Config{})
+	defer b.Stop()
+
+	b.Respond("billing.plan", func(_ context.Context, req any) (any, error) {
+		userID := req.(int)
+		if userID == 0 {
+			return nil, errors.New("unknown user")
+		}
+		return fmt.Sprintf("plan-%d", userID), nil
+	})
+
+	// concurrent requests get their own replies
+	var wg sync.WaitGroup
+	for i := 1; i <= 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := b.Request(context.Background(), "billing.plan", i)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if want := fmt.Sprintf("plan-%d", i); got != want {
+				t.Errorf("expected %s got %v", want, got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := b.Request(context.Background(), "billing.plan", 0); err == nil || err.Error() != "unknown user" {
+		t.Errorf("expected responder error, got %v", err)
+	}
+}
+
+func TestRequestNoResponder(t *testing.T) {
+	b := New(Config{})
+	defer b.Stop()
+
+	// ordinary subscribers do not answer requests
+	b.Subscribe("billing.none", func(context.Context, any) {})
+	if _, err := b.Request(context.Background(), "billing.none", nil); !errors.Is(err, ErrNoResponder) {
+		t.Errorf("expected ErrNoResponder got %v", err)
+	}
+}
+
+func TestRequestWildcardSubscribers(t *testing.T) {
+	b := New(Config{})
+	defer b.Stop()
+
+	got := make(chan string, 4)
+	for _, pattern := range []string{">", "*.billing.plan", "*.plan"} {
+		b.Subscribe(pattern, func(ctx context.Context, _ any) {
+			got <- TopicFromContext(ctx)
+		})
+	}
+	// wildcard subscribers are not responders
+	if _, err := b.Request(context.Background(), "billing.plan", 1); !errors.Is(err, ErrNoResponder) {
+		t.Fatalf("expected ErrNoResponder got %v", err)
+	}
+
+	b.Respond("billing.plan", func(context.Context, any) (any, error) { return "pro", nil })
+	if v, err := b.Request(context.Background(), "billing.plan", 1); err != nil || v != "pro" {
+		t.Fatalf("expected pro got %v %v", v, err)
+	}
+	flush(t, b)
+	if len(got) != 0 {
+		t.Fatalf("expected no request to reach wildcard subscribers, got %s", <-got)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	b := New(Config{RequestTimeout: 10 * time.Millisecond})
+	defer b.Stop()
+
+	b.Respond("billing.slow", func(ctx context.Context, _ any) (any, error) {
+		time.Sleep(50 * time.Millisecond)
+		return "late", nil
+	})
+	if _, err := b.Request(context.Background(), "billing.slow", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded got %v", err)
+	}
+
+	b.Respond("billing.panic", func(context.Context, any) (any, error) { panic("boom") })
+	if _, err := b.Request(context.Background(), "billing.panic", nil); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected panic to be returned as error, got %v", err)
+	}
+}
@@ -0,0 +1,300 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DeadLetterTopic is the topic events are published to, wrapped in a
+// DeadLetter, after an error-returning handler exhausted its retries.
+const DeadLetterTopic = "event.deadletter"
+
+// ErrHandlerFunc is a handler that reports failure by returning an error.
+// Failed invocations are retried according to the RetryPolicy of the
+// subscription.
+type ErrHandlerFunc func(context.Context, any) error
+
+// RetryPolicy configures how a failing ErrHandlerFunc is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of invocations, including the first
+	// one. Defaults to 3.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to
+	// 100 milliseconds.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries. Defaults to 30 seconds.
+	MaxBackoff time.Duration
+	// Multiplier is applied to the delay after each retry. Defaults to 2.
+	Multiplier float64
+	// Jitter randomizes each delay by up to this fraction in either
+	// direction, so failing handlers do not retry in lockstep. Defaults to
+	// 0.2; set a negative value to disable it.
+	Jitter float64
+	// Retryable reports whether err is worth retrying. By default every
+	// error is retried except those wrapped with Permanent.
+	Retryable func(err error) bool
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 30 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	if p.Jitter == 0 {
+		p.Jitter = 0.2
+	}
+	if p.Retryable == nil {
+		p.Retryable = func(err error) bool { return !IsPermanent(err) }
+	}
+	return p
+}
+
+// backoff returns the delay after the given failed attempt, starting at 1.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff)
+	for i := 1; i < attempt && d < float64(p.MaxBackoff); i++ {
+		d *= p.Multiplier
+	}
+	d = min(d, float64(p.MaxBackoff))
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(d)
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not retryable. The event is dead-lettered straight
+// away.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Attempt records a failed handler invocation.
+type Attempt struct {
+	At  time.Time `json:"at"`
+	Err string    `json:"error"`
+}
+
+// DeadLetter is an event an error-returning handler gave up on.
+type DeadLetter struct {
+	ID uint64 `json:"id"`
+	// Topic is the topic the event was emitted to.
+	Topic string `json:"topic"`
+	// SubscriptionID identifies the subscription that failed. Replaying
+	// the dead letter invokes this subscription again.
+	SubscriptionID uint64    `json:"subscription_id"`
+	Payload        any       `json:"payload"`
+	Attempts       []Attempt `json:"attempts"`
+	FailedAt       time.Time `json:"failed_at"`
+}
+
+// SubscribeErr subscribes an error-returning handler to the given topic.
+// Failed invocations are retried according to policy; once the attempts are
+// exhausted, or the error is not retryable, the event is kept as a
+// DeadLetter and published to DeadLetterTopic.
+func SubscribeErr(topic string, h ErrHandlerFunc, policy RetryPolicy, opts ...SubscribeOption) Subscription {
+	return Default().SubscribeErr(topic, h, policy, opts...)
+}
+
+// SubscribeErr subscribes an error-returning handler to the given topic. See
+// the package level SubscribeErr.
+func (b *Bus) SubscribeErr(topic string, h ErrHandlerFunc, policy RetryPolicy, opts ...SubscribeOption) Subscription {
+	policy = policy.withDefaults()
+	id := subIDCounter.Add(1)
+	b.retryHandlers.Store(id, retryHandler{fn: h, policy: policy})
+	return b.subscribeID(id, topic, func(ctx context.Context, msg any) {
+		attempts, err := runWithRetry(ctx, h, policy, msg)
+		if err != nil {
+			emitted := TopicFromContext(ctx)
+			if emitted == "" {
+				emitted = topic
+			}
+			deliveringBus(ctx, b).deadLetter(ctx, id, emitted, msg, attempts)
+		}
+	}, opts...)
+}
+
+type retryHandler struct {
+	fn     ErrHandlerFunc
+	policy RetryPolicy
+}
+
+// runWithRetry invokes h until it succeeds or policy gives up. It returns the
+// failed attempts and the last error.
+func runWithRetry(ctx context.Context, h ErrHandlerFunc, policy RetryPolicy, msg any) ([]Attempt, error) {
+	var attempts []Attempt
+	for n := 1; ; n++ {
+		err := h(ctx, msg)
+		if err == nil {
+			return attempts, nil
+		}
+		attempts = append(attempts, Attempt{At: time.Now(), Err: err.Error()})
+		if n >= policy.MaxAttempts || !policy.Retryable(err) {
+			return attempts, err
+		}
+		timer := time.NewTimer(policy.backoff(n))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			attempts = append(attempts, Attempt{At: time.Now(), Err: fmt.Sprintf("retry aborted: %v", ctx.Err())})
+			return attempts, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// maxDeadLetters bounds the dead letters kept in memory. The oldest are
+// discarded first; subscribe to DeadLetterTopic to persist them.
+const maxDeadLetters = 1000
+
+// deadLetterIDs numbers dead letters across buses, so they keep their IDs
+// when Configure moves them to a new bus.
+var deadLetterIDs atomic.Uint64
+
+// deadLetterStore keeps the dead letters of a Bus.
+type deadLetterStore struct {
+	mu      sync.Mutex
+	letters []DeadLetter
+}
+
+// adopt moves the dead letters of prev in front of those of s.
+func (s *deadLetterStore) adopt(prev *deadLetterStore) {
+	prev.mu.Lock()
+	letters := prev.letters
+	prev.letters = nil
+	prev.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.letters = append(letters, s.letters...)
+	if n := len(s.letters) - maxDeadLetters; n > 0 {
+		s.letters = slices.Delete(s.letters, 0, n)
+	}
+}
+
+func (b *Bus) deadLetter(ctx context.Context, subID uint64, topic string, msg any, attempts []Attempt) {
+	if topic == DeadLetterTopic {
+		// never dead-letter dead letters
+		slog.Error("dead letter handler failed", "attempts", attempts)
+		return
+	}
+	markFailed(ctx)
+	dl := DeadLetter{
+		ID:             deadLetterIDs.Add(1),
+		Topic:          topic,
+		SubscriptionID: subID,
+		Payload:        msg,
+		Attempts:       attempts,
+		FailedAt:       time.Now(),
+	}
+	b.deadLetters.mu.Lock()
+	if len(b.deadLetters.letters) >= maxDeadLetters {
+		b.deadLetters.letters = slices.Delete(b.deadLetters.letters, 0, 1)
+	}
+	b.deadLetters.letters = append(b.deadLetters.letters, dl)
+	b.deadLetters.mu.Unlock()
+
+	slog.Error("event handler failed; dead-lettered",
+		"topic", topic, "subscription", subID, "attempts", len(attempts), "err", attempts[len(attempts)-1].Err)
+	if err := b.TryEmit(DeadLetterTopic, dl); err != nil && ctx.Err() == nil {
+		slog.Warn("publishing dead letter failed", "topic", topic, "err", err)
+	}
+}
+
+// DeadLetters returns the dead letters kept in memory by the default bus,
+// oldest first.
+func DeadLetters() []DeadLetter {
+	return Default().DeadLetters()
+}
+
+// ReplayDeadLetter replays a dead letter of the default bus, see
+// Bus.ReplayDeadLetter.
+func ReplayDeadLetter(ctx context.Context, id uint64) error {
+	return Default().ReplayDeadLetter(ctx, id)
+}
+
+// DiscardDeadLetter removes a dead letter of the default bus without
+// replaying it.
+func DiscardDeadLetter(id uint64) {
+	Default().DiscardDeadLetter(id)
+}
+
+// DeadLetters returns the dead letters kept in memory, oldest first.
+func (b *Bus) DeadLetters() []DeadLetter {
+	b.deadLetters.mu.Lock()
+	defer b.deadLetters.mu.Unlock()
+	return slices.Clone(b.deadLetters.letters)
+}
+
+// ReplayDeadLetter invokes the failed subscription again with the payload of
+// the dead letter, retrying according to its policy. On success the dead
+// letter is removed; otherwise the new attempts are added to its history.
+func (b *Bus) ReplayDeadLetter(ctx context.Context, id uint64) error {
+	b.deadLetters.mu.Lock()
+	i := slices.IndexFunc(b.deadLetters.letters, func(dl DeadLetter) bool { return dl.ID == id })
+	if i < 0 {
+		b.deadLetters.mu.Unlock()
+		return fmt.Errorf("event: dead letter %d not found", id)
+	}
+	dl := b.deadLetters.letters[i]
+	b.deadLetters.mu.Unlock()
+
+	v, ok := b.retryHandlers.Load(dl.SubscriptionID)
+	if !ok {
+		return fmt.Errorf("event: subscription %d of dead letter %d no longer exists", dl.SubscriptionID, id)
+	}
+	h := v.(retryHandler)
+	ctx = context.WithValue(ctx, topicKey{}, dl.Topic)
+	attempts, err := runWithRetry(ctx, h.fn, h.policy, dl.Payload)
+
+	b.deadLetters.mu.Lock()
+	defer b.deadLetters.mu.Unlock()
+	i = slices.IndexFunc(b.deadLetters.letters, func(dl DeadLetter) bool { return dl.ID == id })
+	if i < 0 {
+		return err
+	}
+	if err == nil {
+		b.deadLetters.letters = slices.Delete(b.deadLetters.letters, i, i+1)
+		return nil
+	}
+	b.deadLetters.letters[i].Attempts = append(b.deadLetters.letters[i].Attempts, attempts...)
+	b.deadLetters.letters[i].FailedAt = time.Now()
+	return err
+}
+
+// DiscardDeadLetter removes a dead letter without replaying it.
+func (b *Bus) DiscardDeadLetter(id uint64) {
+	b.deadLetters.mu.Lock()
+	defer b.deadLetters.mu.Unlock()
+	b.deadLetters.letters = slices.DeleteFunc(b.deadLetters.letters, func(dl DeadLetter) bool {
+		return dl.ID == id
+	})
+}
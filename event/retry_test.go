@@ -0,0 +1,131 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, Jitter: -1}.withDefaults()
+	for attempt, want := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 40 * time.Millisecond, 4: 50 * time.Millisecond} {
+		if got := p.backoff(attempt); got != want {
+			t.Errorf("attempt %d: expected %s got %s", attempt, want, got)
+		}
+	}
+
+	p.Jitter = 0.5
+	for range 100 {
+		if d := p.backoff(1); d < 5*time.Millisecond || d > 15*time.Millisecond {
+			t.Fatalf("jittered backoff %s out of range", d)
+		}
+	}
+}
+
+func TestSubscribeErrRetries(t *testing.T) {
+	var calls atomic.Int32
+	done := make(chan struct{})
+	sub := SubscribeErr("retry.ok", func(context.Context, any) error {
+		if calls.Add(1) < 3 {
+			return errors.New("temporary")
+		}
+		close(done)
+		return nil
+	}, RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	defer Unsubscribe(sub)
+
+	Emit("retry.ok", 1)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("handler did not succeed, called %d times", calls.Load())
+	}
+}
+
+func TestDeadLetterReplay(t *testing.T) {
+	dead := make(chan DeadLetter, 1)
+	dlSub := Subscribe(DeadLetterTopic, func(_ context.Context, event any) {
+		if dl := event.(DeadLetter); dl.Topic == "retry.fail.a" {
+			dead <- dl
+		}
+	})
+	defer Unsubscribe(dlSub)
+
+	var fail atomic.Bool
+	fail.Store(true)
+	var calls atomic.Int32
+	sub := SubscribeErr("retry.fail.*", func(context.Context, any) error {
+		calls.Add(1)
+		if fail.Load() {
+			return Permanent(errors.New("invalid address"))
+		}
+		return nil
+	}, RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Millisecond})
+	defer Unsubscribe(sub)
+
+	Emit("retry.fail.a", "payload")
+	var dl DeadLetter
+	select {
+	case dl = <-dead:
+	case <-time.After(time.Second):
+		t.Fatal("expected dead letter")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected permanent error not to be retried, got %d calls", calls.Load())
+	}
+	if dl.SubscriptionID != sub.ID || dl.Payload != "payload" || len(dl.Attempts) != 1 || dl.Attempts[0].Err != "invalid address" {
+		t.Errorf("unexpected dead letter %+v", dl)
+	}
+
+	if err := ReplayDeadLetter(context.Background(), dl.ID); err == nil {
+		t.Fatal("expected replay to fail")
+	}
+	fail.Store(false)
+	if err := ReplayDeadLetter(context.Background(), dl.ID); err != nil {
+		t.Fatal(err)
+	}
+	for _, letter := range DeadLetters() {
+		if letter.ID == dl.ID {
+			t.Errorf("expected dead letter to be removed after successful replay")
+		}
+	}
+}
+
+func TestDeadLettersAfterConfigure(t *testing.T) {
+	sub := SubscribeErr("retry.configure", func(context.Context, any) error {
+		return Permanent(errors.New("failed"))
+	}, RetryPolicy{})
+	defer Unsubscribe(sub)
+
+	Emit("retry.configure", "before")
+	if err := Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	Configure(Config{})
+
+	dead := make(chan DeadLetter, 1)
+	dlSub := Subscribe(DeadLetterTopic, func(_ context.Context, event any) {
+		if dl := event.(DeadLetter); dl.Topic == "retry.configure" {
+			dead <- dl
+		}
+	})
+	defer Unsubscribe(dlSub)
+	Emit("retry.configure", "after")
+	select {
+	case <-dead:
+	case <-time.After(time.Second):
+		t.Fatal("expected the dead letter on the new default bus")
+	}
+
+	var payloads []any
+	for _, dl := range DeadLetters() {
+		if dl.SubscriptionID == sub.ID {
+			payloads = append(payloads, dl.Payload)
+		}
+	}
+	if len(payloads) != 2 || payloads[0] != "before" || payloads[1] != "after" {
+		t.Errorf("expected both dead letters to be kept, got %v", payloads)
+	}
+}
@@ -0,0 +1,192 @@
+package event
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/superkit/db"
+)
+
+// Scheduled is a handle to an event scheduled with EmitAt or EmitAfter.
+type Scheduled struct {
+	b       *Bus
+	topic   string
+	message any
+	at      time.Time
+	// position in the schedule queue, -1 once fired or cancelled
+	index int
+}
+
+// Topic returns the topic the event is emitted to.
+func (s *Scheduled) Topic() string {
+	return s.topic
+}
+
+// At returns the time the event is due.
+func (s *Scheduled) At() time.Time {
+	return s.at
+}
+
+// Cancel cancels the event. It reports whether the event was still pending;
+// false means it was already emitted or cancelled.
+func (s *Scheduled) Cancel() bool {
+	q := &s.b.schedule
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if s.index < 0 {
+		return false
+	}
+	heap.Remove(&q.queue, s.index)
+	return true
+}
+
+// schedule holds the events scheduled on a bus in a min-heap ordered by due
+// time. A single goroutine sleeps until the earliest one is due.
+type schedule struct {
+	mu    sync.Mutex
+	queue scheduleQueue
+	// wakes the scheduler when the earliest event changed
+	wake chan struct{}
+}
+
+type scheduleQueue []*Scheduled
+
+func (q scheduleQueue) Len() int           { return len(q) }
+func (q scheduleQueue) Less(i, j int) bool { return q[i].at.Before(q[j].at) }
+
+func (q scheduleQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *scheduleQueue) Push(x any) {
+	s := x.(*Scheduled)
+	s.index = len(*q)
+	*q = append(*q, s)
+}
+
+func (q *scheduleQueue) Pop() any {
+	old := *q
+	s := old[len(old)-1]
+	old[len(old)-1] = nil
+	s.index = -1
+	*q = old[:len(old)-1]
+	return s
+}
+
+// EmitAt emits an event to the given topic on the default bus at the given
+// time. See Bus.EmitAt.
+func EmitAt(at time.Time, topic string, event any) *Scheduled {
+	return Default().EmitAt(at, topic, event)
+}
+
+// EmitAfter emits an event to the given topic on the default bus once d has
+// elapsed. See Bus.EmitAt.
+func EmitAfter(d time.Duration, topic string, event any) *Scheduled {
+	return Default().EmitAfter(d, topic, event)
+}
+
+// EmitAt emits an event to the given topic at the given time, like Emit.
+// The returned handle cancels the event. Scheduled events are kept in
+// memory and are lost when the bus is stopped or the process exits; use
+// EmitTxAt for events that must survive restarts.
+func (b *Bus) EmitAt(at time.Time, topic string, event any) *Scheduled {
+	s := &Scheduled{b: b, topic: topic, message: event, at: at}
+	q := &b.schedule
+	q.mu.Lock()
+	heap.Push(&q.queue, s)
+	earliest := s.index == 0
+	q.mu.Unlock()
+	if earliest {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return s
+}
+
+// EmitAfter emits an event to the given topic once d has elapsed. See
+// EmitAt.
+func (b *Bus) EmitAfter(d time.Duration, topic string, event any) *Scheduled {
+	return b.EmitAt(time.Now().Add(d), topic, event)
+}
+
+// Scheduled returns the number of events scheduled with EmitAt and
+// EmitAfter that are not yet due.
+func (b *Bus) Scheduled() int {
+	b.schedule.mu.Lock()
+	defer b.schedule.mu.Unlock()
+	return b.schedule.queue.Len()
+}
+
+// runSchedule emits scheduled events when they are due until the bus is
+// stopped.
+func (b *Bus) runSchedule() {
+	q := &b.schedule
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+	for {
+		var due []*Scheduled
+		next := time.Duration(-1)
+		now := time.Now()
+		q.mu.Lock()
+		for q.queue.Len() > 0 && !q.queue[0].at.After(now) {
+			due = append(due, heap.Pop(&q.queue).(*Scheduled))
+		}
+		if q.queue.Len() > 0 {
+			next = q.queue[0].at.Sub(now)
+		}
+		q.mu.Unlock()
+
+		for _, s := range due {
+			b.Emit(s.topic, s.message)
+		}
+		if len(due) > 0 {
+			// emitting took time, look again
+			continue
+		}
+
+		var fire <-chan time.Time
+		if next >= 0 {
+			timer.Reset(next)
+			fire = timer.C
+		}
+		select {
+		case <-b.ctx.Done():
+			timer.Stop()
+			return
+		case <-q.wake:
+			timer.Stop()
+		case <-fire:
+		}
+	}
+}
+
+// EmitTxAt writes an event for topic to the outbox using tx like EmitTx,
+// to be delivered by an Outbox dispatcher at the given time. Unlike EmitAt
+// the event survives restarts. It returns the ID of the outbox row, which
+// CancelTx takes. Delivery is accurate to the dispatcher's poll interval.
+func EmitTxAt(ctx context.Context, tx Execer, at time.Time, topic string, event any) (int64, error) {
+	return insertOutbox(ctx, tx, at, topic, event, true)
+}
+
+// CancelTx cancels an event written with EmitTxAt. It reports whether the
+// event was still pending; false means it was already delivered, is being
+// delivered, or did not exist.
+func CancelTx(ctx context.Context, tx Execer, id int64) (bool, error) {
+	now := time.Now().UTC()
+	res, err := tx.ExecContext(ctx, db.Rebind(currentOutboxDriver(),
+		"delete from "+OutboxTable+" where id = ? and done_at is null and failed_at is null"+
+			" and (locked_until is null or locked_until <= ?)"),
+		id, now)
+	if err != nil {
+		return false, fmt.Errorf("event: cancel scheduled event %d: %w", id, err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,100 @@
+package event
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEmitAfterOrder(t *testing.T) {
+	b := New(Config{})
+	defer b.Stop()
+
+	got := make(chan int, 3)
+	b.Subscribe("later", func(_ context.Context, msg any) {
+		got <- msg.(int)
+	})
+	b.EmitAfter(60*time.Millisecond, "later", 3)
+	b.EmitAfter(20*time.Millisecond, "later", 1)
+	b.EmitAt(time.Now().Add(40*time.Millisecond), "later", 2)
+
+	seen := []int{<-got, <-got, <-got}
+	if !slices.Equal(seen, []int{1, 2, 3}) {
+		t.Fatalf("expected [1 2 3] got %v", seen)
+	}
+}
+
+func TestScheduledCancel(t *testing.T) {
+	b := New(Config{})
+	defer b.Stop()
+
+	got := make(chan int, 2)
+	b.Subscribe("later", func(_ context.Context, msg any) {
+		got <- msg.(int)
+	})
+	s := b.EmitAfter(20*time.Millisecond, "later", 1)
+	b.EmitAfter(40*time.Millisecond, "later", 2)
+	if !s.Cancel() {
+		t.Fatal("expected pending event to be cancelled")
+	}
+	if s.Cancel() {
+		t.Fatal("expected second cancel to report false")
+	}
+	if v := <-got; v != 2 {
+		t.Fatalf("expected 2 got %d", v)
+	}
+	if b.Scheduled() != 0 {
+		t.Fatalf("expected no scheduled events, got %d", b.Scheduled())
+	}
+}
+
+func TestEmitAtBurst(t *testing.T) {
+	b := New(Config{BufferSize: 16, Overflow: Block})
+	defer b.Stop()
+
+	const n = 1000
+	var wg sync.WaitGroup
+	wg.Add(n)
+	b.Subscribe("burst", func(context.Context, any) {
+		wg.Done()
+	})
+	at := time.Now().Add(20 * time.Millisecond)
+	for i := range n {
+		b.EmitAt(at, "burst", i)
+	}
+	if err := waitContext(timeout(t, 5*time.Second), &wg); err != nil {
+		t.Fatalf("not all scheduled events were delivered: %v", err)
+	}
+}
+
+func timeout(t *testing.T, d time.Duration) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestEmitTxAt(t *testing.T) {
+	tx := recordingExecer{result: insertResult(42)}
+	at := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	id, err := EmitTxAt(context.Background(), &tx, at, "profile.reminder", "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 42 {
+		t.Fatalf("expected id 42 got %d", id)
+	}
+	if tx.args[3] != at {
+		t.Fatalf("expected available_at %v got %v", at, tx.args[3])
+	}
+
+	ok, err := CancelTx(context.Background(), &tx, id)
+	if err != nil || !ok {
+		t.Fatalf("expected cancel to succeed, got %v %v", ok, err)
+	}
+	if !strings.HasPrefix(tx.query, "delete from "+OutboxTable) || tx.args[0] != int64(42) {
+		t.Fatalf("unexpected cancel %q %v", tx.query, tx.args)
+	}
+}
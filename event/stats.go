@@ -0,0 +1,209 @@
+package event
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BusStats is a snapshot of the state of a Bus.
+type BusStats struct {
+	// Queued and Dropped are the running totals of the bus, Pending the
+	// number of events waiting in the buffer.
+	Counters
+	// Capacity is the size of the event buffer.
+	Capacity int `json:"capacity"`
+	// Scheduled is the number of events scheduled with EmitAt that are not
+	// yet due.
+	Scheduled int `json:"scheduled"`
+	// Topics holds a line per subscribed pattern and per emitted topic,
+	// sorted by topic.
+	Topics []TopicStats `json:"topics"`
+}
+
+// TopicStats are the statistics of a topic or subscription pattern.
+type TopicStats struct {
+	Topic string `json:"topic"`
+	// Subscribers is the number of subscriptions receiving events on the
+	// topic, including wildcard subscriptions.
+	Subscribers int `json:"subscribers"`
+	// Emitted is the number of events queued for the topic.
+	Emitted uint64 `json:"emitted"`
+	// Dropped is the number of events for the topic that were dropped.
+	Dropped uint64 `json:"dropped"`
+	// Handled is the number of handler invocations for the topic.
+	Handled uint64 `json:"handled"`
+	// Failed is the number of handler invocations that failed: panics
+	// recovered by Recover and events dead-lettered by SubscribeErr.
+	Failed uint64 `json:"failed"`
+	// Latency summarises the duration of recent handler invocations.
+	Latency Latency `json:"latency"`
+}
+
+// Latency holds percentiles of handler durations, computed over the most
+// recent invocations.
+type Latency struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// Stats returns a snapshot of the default bus. See Bus.Stats.
+func Stats() BusStats {
+	return Default().Stats()
+}
+
+// Stats returns a snapshot of the subscribers, counters, queue depth and
+// handler latencies of the bus.
+func (b *Bus) Stats() BusStats {
+	s := BusStats{
+		Counters:  b.Counters(),
+		Capacity:  cap(b.eventch),
+		Scheduled: b.Scheduled(),
+	}
+
+	seen := make(map[string]bool)
+	b.stats.mu.RLock()
+	for name := range b.stats.topics {
+		seen[name] = true
+	}
+	b.stats.mu.RUnlock()
+
+	b.mu.RLock()
+	for pattern := range b.subs {
+		seen[pattern] = true
+	}
+	names := slices.Collect(maps.Keys(seen))
+	slices.Sort(names)
+	s.Topics = make([]TopicStats, 0, len(names))
+	for _, name := range names {
+		ts := TopicStats{Topic: name}
+		if isPattern(name) {
+			ts.Subscribers = len(b.subs[name])
+		} else {
+			// wildcard subscriptions receive the topic as well
+			ts.Subscribers = len(b.trie.match(name))
+		}
+		s.Topics = append(s.Topics, ts)
+	}
+	b.mu.RUnlock()
+
+	for i := range s.Topics {
+		b.stats.read(&s.Topics[i])
+	}
+	return s
+}
+
+func isPattern(topic string) bool {
+	for _, seg := range strings.Split(topic, ".") {
+		if seg == wildcardOne || seg == wildcardRest {
+			return true
+		}
+	}
+	return false
+}
+
+// latencySamples is the number of recent handler durations kept per topic.
+const latencySamples = 512
+
+// stats collects per-topic statistics of a bus.
+type stats struct {
+	mu     sync.RWMutex
+	topics map[string]*topicStats
+}
+
+type topicStats struct {
+	emitted atomic.Uint64
+	dropped atomic.Uint64
+	handled atomic.Uint64
+	failed  atomic.Uint64
+
+	mu      sync.Mutex
+	samples [latencySamples]time.Duration
+	next    int
+	full    bool
+}
+
+func (s *stats) topic(name string) *topicStats {
+	s.mu.RLock()
+	t, ok := s.topics[name]
+	s.mu.RUnlock()
+	if ok {
+		return t
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.topics[name]; ok {
+		return t
+	}
+	if s.topics == nil {
+		s.topics = make(map[string]*topicStats)
+	}
+	t = &topicStats{}
+	s.topics[name] = t
+	return t
+}
+
+func (s *stats) emitted(topic string) { s.topic(topic).emitted.Add(1) }
+func (s *stats) dropped(topic string) { s.topic(topic).dropped.Add(1) }
+func (s *stats) failed(topic string)  { s.topic(topic).failed.Add(1) }
+
+func (s *stats) handled(topic string, d time.Duration) {
+	t := s.topic(topic)
+	t.handled.Add(1)
+	t.mu.Lock()
+	t.samples[t.next] = d
+	t.next = (t.next + 1) % latencySamples
+	t.full = t.full || t.next == 0
+	t.mu.Unlock()
+}
+
+// read fills in the counters and latencies of ts.
+func (s *stats) read(ts *TopicStats) {
+	s.mu.RLock()
+	t, ok := s.topics[ts.Topic]
+	s.mu.RUnlock()
+	if !ok {
+		return
+	}
+	ts.Emitted = t.emitted.Load()
+	ts.Dropped = t.dropped.Load()
+	ts.Handled = t.handled.Load()
+	ts.Failed = t.failed.Load()
+
+	t.mu.Lock()
+	n := t.next
+	if t.full {
+		n = latencySamples
+	}
+	samples := slices.Clone(t.samples[:n])
+	t.mu.Unlock()
+	if len(samples) == 0 {
+		return
+	}
+	slices.Sort(samples)
+	percentile := func(p float64) time.Duration {
+		return samples[int(p*float64(len(samples)-1))]
+	}
+	ts.Latency = Latency{
+		P50: percentile(0.50),
+		P90: percentile(0.90),
+		P99: percentile(0.99),
+		Max: samples[len(samples)-1],
+	}
+}
+
+type failureKey struct{}
+
+// markFailed records that the handler running with ctx failed, so the
+// invocation counts as failed in Stats.
+func markFailed(ctx context.Context) {
+	if failed, ok := ctx.Value(failureKey{}).(*atomic.Bool); ok {
+		failed.Store(true)
+	}
+}
@@ -0,0 +1,46 @@
+package event
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	b := New(Config{})
+	defer b.Stop()
+
+	b.Subscribe("stats.a", func(context.Context, any) {
+		time.Sleep(5 * time.Millisecond)
+	})
+	b.Subscribe("stats.*", func(context.Context, any) {}, WithMiddleware(Recover()), WithMiddleware(func(HandlerFunc) HandlerFunc {
+		return func(context.Context, any) { panic("boom") }
+	}))
+	for range 3 {
+		if err := b.EmitAndWait(context.Background(), "stats.a", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := b.Stats()
+	if s.Queued != 3 || s.Capacity != 128 {
+		t.Fatalf("unexpected totals %+v", s.Counters)
+	}
+	byTopic := make(map[string]TopicStats)
+	for _, ts := range s.Topics {
+		byTopic[ts.Topic] = ts
+	}
+	a, ok := byTopic["stats.a"]
+	if !ok {
+		t.Fatalf("expected stats.a in %+v", s.Topics)
+	}
+	if a.Subscribers != 2 || a.Emitted != 3 || a.Handled != 6 || a.Failed != 3 {
+		t.Fatalf("unexpected stats %+v", a)
+	}
+	if a.Latency.Max < 5*time.Millisecond || a.Latency.P50 > a.Latency.Max {
+		t.Fatalf("unexpected latency %+v", a.Latency)
+	}
+	if w := byTopic["stats.*"]; w.Subscribers != 1 {
+		t.Fatalf("expected pattern with one subscriber, got %+v", w)
+	}
+}
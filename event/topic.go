@@ -0,0 +1,99 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"time"
+)
+
+// Topic is a typed event topic. It is declared once and used by both
+// emitters and subscribers, so the payload type is checked at compile time:
+//
+//	var UserSignup = event.NewTopic[auth.UserWithVerificationToken]("auth.signup")
+//
+//	UserSignup.Subscribe(func(ctx context.Context, u auth.UserWithVerificationToken) { ... })
+//	UserSignup.Emit(ctx, u)
+//
+// A Topic is a thin layer over the string-topic API, so events emitted with
+// Emit(topic.Name(), v) reach typed subscribers and vice versa.
+type Topic[T any] struct {
+	name string
+}
+
+// NewTopic returns the typed topic with the given name. T is registered as
+// the payload type of the topic, see RegisterType.
+func NewTopic[T any](name string) Topic[T] {
+	registerType(name, reflect.TypeFor[T]())
+	return Topic[T]{name: name}
+}
+
+// Name returns the name of the topic.
+func (t Topic[T]) Name() string {
+	return t.name
+}
+
+// Emit emits v to the topic. It behaves like EmitContext.
+func (t Topic[T]) Emit(ctx context.Context, v T) error {
+	return EmitContext(ctx, t.name, v)
+}
+
+// TryEmit emits v to the topic without blocking. It behaves like TryEmit.
+func (t Topic[T]) TryEmit(v T) error {
+	return TryEmit(t.name, v)
+}
+
+// EmitTx writes v to the outbox within tx. It behaves like EmitTx.
+func (t Topic[T]) EmitTx(ctx context.Context, tx Execer, v T) error {
+	return EmitTx(ctx, tx, t.name, v)
+}
+
+// EmitAt emits v to the topic at the given time. It behaves like EmitAt.
+func (t Topic[T]) EmitAt(at time.Time, v T) *Scheduled {
+	return EmitAt(at, t.name, v)
+}
+
+// EmitAfter emits v to the topic once d has elapsed. It behaves like
+// EmitAfter.
+func (t Topic[T]) EmitAfter(d time.Duration, v T) *Scheduled {
+	return EmitAfter(d, t.name, v)
+}
+
+// EmitTxAt writes v to the outbox within tx, to be delivered at the given
+// time. It behaves like EmitTxAt.
+func (t Topic[T]) EmitTxAt(ctx context.Context, tx Execer, at time.Time, v T) (int64, error) {
+	return EmitTxAt(ctx, tx, at, t.name, v)
+}
+
+// Subscribe subscribes h to the topic.
+func (t Topic[T]) Subscribe(h func(context.Context, T), opts ...SubscribeOption) Subscription {
+	return Subscribe(t.name, t.Handler(h), opts...)
+}
+
+// SubscribeErr subscribes an error-returning handler to the topic. It
+// behaves like SubscribeErr.
+func (t Topic[T]) SubscribeErr(h func(context.Context, T) error, policy RetryPolicy, opts ...SubscribeOption) Subscription {
+	return SubscribeErr(t.name, func(ctx context.Context, event any) error {
+		v, ok := event.(T)
+		if !ok && event != nil {
+			return Permanent(fmt.Errorf("event payload for %s has type %T", t.name, event))
+		}
+		return h(ctx, v)
+	}, policy, opts...)
+}
+
+// Handler adapts h to a HandlerFunc for the topic, for use with wrappers
+// that take a HandlerFunc. Events of another type, which can only be emitted
+// through the string-topic API, are logged and skipped.
+func (t Topic[T]) Handler(h func(context.Context, T)) HandlerFunc {
+	return func(ctx context.Context, event any) {
+		v, ok := event.(T)
+		if !ok && event != nil {
+			slog.Error("event payload has wrong type; skipping handler",
+				"topic", t.name, "want", fmt.Sprintf("%T", v), "got", fmt.Sprintf("%T", event))
+			return
+		}
+		h(ctx, v)
+	}
+}
@@ -0,0 +1,169 @@
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Transport connects a Bus to buses in other processes. Events emitted on
+// the bus are published through the transport to remote buses with
+// subscribers for their topic, and events received from them are delivered
+// to the local subscribers.
+//
+// Without a transport, or with InProcess, events stay in the process and
+// are only delivered through the bus's channel.
+type Transport interface {
+	// Start starts the transport. deliver is called for every event
+	// received from a remote bus.
+	Start(deliver func(topic string, payload any)) error
+	// Publish sends an event emitted on the local bus to the remote buses
+	// with subscribers for topic. It must not block.
+	Publish(topic string, payload any) error
+	// Register announces the topic patterns the local bus has subscribers
+	// for, replacing the previously registered patterns.
+	Register(patterns []string) error
+	// Close stops the transport.
+	Close() error
+}
+
+// InProcess is the default transport. It keeps events in the process.
+var InProcess Transport = inProcess{}
+
+type inProcess struct{}
+
+func (inProcess) Start(func(string, any)) error { return nil }
+func (inProcess) Publish(string, any) error     { return nil }
+func (inProcess) Register([]string) error       { return nil }
+func (inProcess) Close() error                  { return nil }
+
+// Codec encodes event payloads sent through a transport. Payloads are
+// decoded into the type registered for their topic with RegisterType or
+// NewTopic.
+type Codec interface {
+	Marshal(topic string, v any) ([]byte, error)
+	Unmarshal(topic string, data []byte) (any, error)
+}
+
+var (
+	// JSON encodes payloads as JSON. Payloads of unregistered topics are
+	// decoded into the generic JSON types.
+	JSON Codec = jsonCodec{}
+	// Gob encodes payloads with encoding/gob. Only payloads of registered
+	// topics can be decoded.
+	Gob Codec = gobCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(_ string, v any) ([]byte, error) {
+	return encodePayload(v)
+}
+
+func (jsonCodec) Unmarshal(topic string, data []byte) (any, error) {
+	return decodePayload(topic, data)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(_ string, v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(topic string, data []byte) (any, error) {
+	t, ok := payloadTypes.Load(topic)
+	if !ok {
+		return nil, fmt.Errorf("event: no payload type registered for %s", topic)
+	}
+	ptr := reflect.New(t.(reflect.Type))
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(ptr.Interface()); err != nil {
+		return nil, err
+	}
+	return ptr.Elem().Interface(), nil
+}
+
+// Connect starts t and connects the default bus through it. See
+// Bus.Connect.
+func Connect(t Transport) error {
+	return Default().Connect(t)
+}
+
+// Connect starts t and connects the bus through it. A bus has at most one
+// transport, which is closed when the bus is stopped. When using Configure,
+// connect afterwards, since Configure replaces the default bus.
+//
+// Requests and the handlers waited for by EmitAndWait stay local. Events
+// written with EmitTx are sent to peers by the Outbox on their first
+// delivery attempt; the peers receive them at most once, without the
+// retries of the outbox.
+func (b *Bus) Connect(t Transport) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed.Load() {
+		return ErrStopped
+	}
+	if b.transport != nil {
+		return errors.New("event: bus already has a transport")
+	}
+	if err := t.Start(b.receive); err != nil {
+		return err
+	}
+	b.transport = t
+	return b.register()
+}
+
+// receive emits an event received from a remote bus to the local
+// subscribers.
+func (b *Bus) receive(topic string, payload any) {
+	ctx, cancel := context.WithTimeout(b.ctx, b.cfg.BlockTimeout)
+	defer cancel()
+	evt := newEvent(topic, payload)
+	evt.remote = true
+	if err := b.emit(ctx, evt, true); err != nil && !errors.Is(err, ErrStopped) {
+		slog.Warn("dropping remote event", "topic", topic, "err", err)
+	}
+}
+
+// publish sends a locally emitted event through the transport.
+func (b *Bus) publish(t Transport, evt event) {
+	if t == nil || evt.remote || strings.HasPrefix(evt.topic, requestTopicPrefix) {
+		return
+	}
+	if err := t.Publish(evt.topic, evt.message); err != nil {
+		slog.Warn("publishing event", "topic", evt.topic, "err", err)
+	}
+}
+
+// forward sends an event delivered by an Outbox through the transport.
+func (b *Bus) forward(topic string, msg any) {
+	b.mu.RLock()
+	t := b.transport
+	b.mu.RUnlock()
+	b.publish(t, newEvent(topic, msg))
+}
+
+// register announces the subscribed topic patterns to the transport. It
+// must be called with b.mu held.
+func (b *Bus) register() error {
+	if b.transport == nil {
+		return nil
+	}
+	patterns := make([]string, 0, len(b.subs))
+	for pattern := range b.subs {
+		if !strings.HasPrefix(pattern, requestTopicPrefix) {
+			patterns = append(patterns, pattern)
+		}
+	}
+	slices.Sort(patterns)
+	return b.transport.Register(patterns)
+}
@@ -0,0 +1,133 @@
+package event
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// wildcardOne matches exactly one topic segment.
+	wildcardOne = "*"
+	// wildcardRest matches one or more trailing topic segments.
+	wildcardRest = ">"
+)
+
+// topicTrie indexes subscriptions by the dot-separated segments of their
+// topic pattern, so matching an emitted topic only visits the branches that
+// can match instead of scanning every subscription.
+type topicTrie struct {
+	root *trieNode
+}
+
+type trieNode struct {
+	children map[string]*trieNode
+	subs     []Subscription
+}
+
+func newTopicTrie() *topicTrie {
+	return &topicTrie{root: &trieNode{}}
+}
+
+// validatePattern panics if pattern is not a valid subscription pattern.
+// Empty segments, as in "auth." or "a..b", are matched literally like any
+// other segment.
+func validatePattern(pattern string) {
+	segments := strings.Split(pattern, ".")
+	for i, seg := range segments {
+		if seg == wildcardRest && i != len(segments)-1 {
+			panic(fmt.Sprintf("event: %q must be the last segment in topic %q", wildcardRest, pattern))
+		}
+	}
+}
+
+func (t *topicTrie) insert(sub Subscription) {
+	n := t.root
+	for _, seg := range strings.Split(sub.Topic, ".") {
+		if n.children == nil {
+			n.children = make(map[string]*trieNode)
+		}
+		child, ok := n.children[seg]
+		if !ok {
+			child = &trieNode{}
+			n.children[seg] = child
+		}
+		n = child
+	}
+	n.subs = append(n.subs, sub)
+}
+
+func (t *topicTrie) remove(sub Subscription) {
+	t.root.remove(strings.Split(sub.Topic, "."), sub.ID)
+}
+
+// remove deletes the subscription with the given id below n and reports
+// whether n is empty afterwards so the caller can prune it.
+func (n *trieNode) remove(segments []string, id uint64) bool {
+	if len(segments) == 0 {
+		n.subs = slices.DeleteFunc(n.subs, func(s Subscription) bool {
+			return s.ID == id
+		})
+	} else if child, ok := n.children[segments[0]]; ok {
+		if child.remove(segments[1:], id) {
+			delete(n.children, segments[0])
+		}
+	}
+	return len(n.subs) == 0 && len(n.children) == 0
+}
+
+// match returns the subscriptions whose pattern matches topic.
+//
+// Requests are only matched by responders: wildcards in the first segment,
+// such as ">" or "*.billing.plan", do not match request topics.
+func (t *topicTrie) match(topic string) []Subscription {
+	var subs []Subscription
+	segments := strings.Split(topic, ".")
+	if strings.HasPrefix(topic, requestTopicPrefix) {
+		if child, ok := t.root.children[segments[0]]; ok {
+			child.match(segments[1:], &subs)
+		}
+		return subs
+	}
+	t.root.match(segments, &subs)
+	return subs
+}
+
+func (n *trieNode) match(segments []string, subs *[]Subscription) {
+	if len(segments) == 0 {
+		*subs = append(*subs, n.subs...)
+		return
+	}
+	// Wildcards in the emitted topic are matched literally through the
+	// wildcard branches below, so skip them here to avoid duplicates.
+	if seg := segments[0]; seg != wildcardOne && seg != wildcardRest {
+		if child, ok := n.children[seg]; ok {
+			child.match(segments[1:], subs)
+		}
+	}
+	if child, ok := n.children[wildcardOne]; ok {
+		child.match(segments[1:], subs)
+	}
+	if child, ok := n.children[wildcardRest]; ok {
+		*subs = append(*subs, child.subs...)
+	}
+}
+
+// matchPattern reports whether the subscription pattern matches topic, like
+// topicTrie.match does for a single pattern.
+func matchPattern(pattern, topic string) bool {
+	patterns := strings.Split(pattern, ".")
+	segments := strings.Split(topic, ".")
+	for i, p := range patterns {
+		if p == wildcardRest {
+			return len(segments) > i
+		}
+		if i >= len(segments) {
+			return false
+		}
+		if p != wildcardOne && p != segments[i] {
+			return false
+		}
+	}
+	return len(patterns) == len(segments)
+}
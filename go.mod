@@ -6,6 +6,7 @@ require (
 	github.com/a-h/templ v0.3.865
 	github.com/gorilla/sessions v1.4.0
 	github.com/joho/godotenv v1.5.1
+	github.com/mattn/go-sqlite3 v1.14.22
 	github.com/stretchr/testify v1.10.0
 )
 
@@ -0,0 +1,341 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft/superkit/db"
+)
+
+// Table is the name of the table jobs are stored in.
+const Table = "jobs"
+
+// SQLiteSchema creates the job tables for sqlite. Workers reserve jobs by
+// inserting a row into job_leases, since sqlite has no row locks.
+const SQLiteSchema = `create table if not exists jobs(
+	id integer primary key,
+	queue text not null,
+	kind text not null,
+	payload text not null,
+	priority integer not null default 0,
+	attempts integer not null default 0,
+	max_attempts integer not null,
+	unique_key text unique,
+	last_error text,
+	run_at datetime not null,
+	created_at datetime not null,
+	finished_at datetime,
+	failed_at datetime
+);
+create index if not exists jobs_pending on jobs(queue, finished_at, failed_at, run_at);
+create table if not exists job_leases(
+	job_id integer primary key,
+	worker text not null,
+	expires_at datetime not null
+);`
+
+// PostgresSchema creates the jobs table for postgres.
+const PostgresSchema = `create table if not exists jobs(
+	id bigserial primary key,
+	queue text not null,
+	kind text not null,
+	payload text not null,
+	priority integer not null default 0,
+	attempts integer not null default 0,
+	max_attempts integer not null,
+	unique_key text unique,
+	last_error text,
+	run_at timestamptz not null,
+	created_at timestamptz not null,
+	finished_at timestamptz,
+	failed_at timestamptz,
+	locked_by text,
+	locked_until timestamptz
+);
+create index if not exists jobs_pending on jobs(queue, run_at) where finished_at is null and failed_at is null;`
+
+// MySQLSchema creates the jobs table for mysql.
+const MySQLSchema = `create table if not exists jobs(
+	id bigint auto_increment primary key,
+	queue varchar(191) not null,
+	kind varchar(191) not null,
+	payload longtext not null,
+	priority int not null default 0,
+	attempts int not null default 0,
+	max_attempts int not null,
+	unique_key varchar(191) unique,
+	last_error text,
+	run_at datetime(6) not null,
+	created_at datetime(6) not null,
+	finished_at datetime(6),
+	failed_at datetime(6),
+	locked_by varchar(191),
+	locked_until datetime(6),
+	index jobs_pending (queue, finished_at, failed_at, run_at)
+);`
+
+// Dialect adapts the queries of the job queue to a database.
+type Dialect interface {
+	// rebind rewrites the ? placeholders of query for the database.
+	rebind(query string) string
+	// onConflict is appended to inserts so a duplicate unique key inserts
+	// nothing instead of failing.
+	onConflict() string
+	// returnsID reports whether inserts return the new ID as a row rather
+	// than through LastInsertId.
+	returnsID() bool
+	// claim reserves up to c.limit due jobs for the worker.
+	claim(ctx context.Context, db *sql.DB, c claim) ([]jobRow, error)
+	// extend renews the reservation of the worker's running jobs.
+	extend(ctx context.Context, db *sql.DB, worker string, ids []int64, until time.Time) error
+	// release drops the worker's reservation of a job.
+	release(ctx context.Context, db *sql.DB, worker string, id int64) error
+	// leasedBy is a condition on the jobs table holding while the worker
+	// bound to its placeholder holds the reservation of the row.
+	leasedBy() string
+}
+
+var (
+	// SQLite reserves jobs through the job_leases table.
+	SQLite Dialect = sqliteDialect{}
+	// Postgres reserves jobs with SELECT ... FOR UPDATE SKIP LOCKED.
+	Postgres Dialect = lockingDialect{
+		driver:    db.DriverPostgres,
+		conflict:  " on conflict (unique_key) do nothing returning id",
+		returning: true,
+	}
+	// MySQL reserves jobs with SELECT ... FOR UPDATE SKIP LOCKED. It
+	// requires MySQL 8.0 or later. A duplicate unique key turns the insert
+	// into a no-op update, which affects no rows.
+	MySQL Dialect = lockingDialect{driver: db.DriverMysql, conflict: " on duplicate key update id = id"}
+)
+
+// DialectFor returns the dialect for a database/sql driver name, or nil if
+// the driver is unknown.
+func DialectFor(driver string) Dialect {
+	switch {
+	case driver == db.DriverSqlite3 || driver == "sqlite":
+		return SQLite
+	case db.IsPostgres(driver):
+		return Postgres
+	case driver == db.DriverMysql:
+		return MySQL
+	}
+	return nil
+}
+
+// claim describes the jobs a worker reserves.
+type claim struct {
+	worker string
+	queues []string
+	kinds  []string
+	now    time.Time
+	until  time.Time
+	limit  int
+}
+
+type jobRow struct {
+	id          int64
+	queue       string
+	kind        string
+	payload     string
+	attempts    int
+	maxAttempts int
+}
+
+// pendingWhere selects the due jobs of c, leaving the reservation check to
+// the dialect. The returned args fill its placeholders.
+func pendingWhere(c claim) (string, []any) {
+	var args []any
+	for _, q := range c.queues {
+		args = append(args, q)
+	}
+	for _, k := range c.kinds {
+		args = append(args, k)
+	}
+	args = append(args, c.now)
+	return "j.queue in (" + placeholders(len(c.queues)) + ") and j.kind in (" + placeholders(len(c.kinds)) + ")" +
+		" and j.finished_at is null and j.failed_at is null and j.run_at <= ?", args
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func scanJobs(rows *sql.Rows) ([]jobRow, error) {
+	defer rows.Close()
+	var jobs []jobRow
+	for rows.Next() {
+		var j jobRow
+		if err := rows.Scan(&j.id, &j.queue, &j.kind, &j.payload, &j.attempts, &j.maxAttempts); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+const jobColumns = "j.id, j.queue, j.kind, j.payload, j.attempts, j.max_attempts"
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) rebind(query string) string { return query }
+func (sqliteDialect) onConflict() string         { return " on conflict (unique_key) do nothing" }
+func (sqliteDialect) returnsID() bool            { return false }
+
+// claim selects due jobs without a live lease and leases them one by one.
+func (d sqliteDialect) claim(ctx context.Context, db *sql.DB, c claim) ([]jobRow, error) {
+	where, args := pendingWhere(c)
+	rows, err := db.QueryContext(ctx,
+		"select "+jobColumns+" from "+Table+" j left join job_leases l on l.job_id = j.id"+
+			" where "+where+" and (l.job_id is null or l.expires_at <= ?)"+
+			" order by j.priority desc, j.run_at, j.id limit ?",
+		append(args, c.now, c.limit)...)
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := scanJobs(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	var claimed []jobRow
+	for _, j := range candidates {
+		ok, err := d.lease(ctx, db, c, j.id)
+		if err != nil {
+			return claimed, err
+		}
+		if !ok {
+			// another worker leased or finished the job first
+			continue
+		}
+		j.attempts++
+		claimed = append(claimed, j)
+	}
+	return claimed, nil
+}
+
+// lease leases job id to c.worker and counts the attempt, in one
+// transaction. The lease is only taken while the job is still due and any
+// previous lease expired, since the job may have been leased, finished or
+// rescheduled by another worker after it was selected.
+func (sqliteDialect) lease(ctx context.Context, db *sql.DB, c claim, id int64) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx,
+		"insert into job_leases (job_id, worker, expires_at)"+
+			" select id, ?, ? from "+Table+
+			" where id = ? and finished_at is null and failed_at is null and run_at <= ?"+
+			" on conflict (job_id) do update set worker = excluded.worker, expires_at = excluded.expires_at"+
+			" where job_leases.expires_at <= ?",
+		c.worker, c.until, id, c.now, c.now)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, "update "+Table+" set attempts = attempts + 1 where id = ?", id); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func (sqliteDialect) leasedBy() string {
+	return "exists (select 1 from job_leases l where l.job_id = " + Table + ".id and l.worker = ?)"
+}
+
+func (sqliteDialect) extend(ctx context.Context, db *sql.DB, worker string, ids []int64, until time.Time) error {
+	args := []any{until, worker}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	_, err := db.ExecContext(ctx,
+		"update job_leases set expires_at = ? where worker = ? and job_id in ("+placeholders(len(ids))+")",
+		args...)
+	return err
+}
+
+func (sqliteDialect) release(ctx context.Context, db *sql.DB, worker string, id int64) error {
+	_, err := db.ExecContext(ctx, "delete from job_leases where job_id = ? and worker = ?", id, worker)
+	return err
+}
+
+// lockingDialect reserves jobs by setting locked_by and locked_until on rows
+// selected with FOR UPDATE SKIP LOCKED, so concurrent workers skip each
+// other's rows instead of waiting for them.
+type lockingDialect struct {
+	// driver is the database/sql driver name, which decides the
+	// placeholder style
+	driver string
+	// conflict is appended to inserts, see Dialect.onConflict
+	conflict string
+	// returning reports whether inserts return the ID as a row
+	returning bool
+}
+
+func (d lockingDialect) rebind(query string) string { return db.Rebind(d.driver, query) }
+
+func (d lockingDialect) onConflict() string { return d.conflict }
+func (d lockingDialect) returnsID() bool    { return d.returning }
+
+func (d lockingDialect) claim(ctx context.Context, db *sql.DB, c claim) ([]jobRow, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	where, args := pendingWhere(c)
+	rows, err := tx.QueryContext(ctx, d.rebind(
+		"select "+jobColumns+" from "+Table+" j"+
+			" where "+where+" and (j.locked_until is null or j.locked_until <= ?)"+
+			" order by j.priority desc, j.run_at, j.id limit ? for update skip locked"),
+		append(args, c.now, c.limit)...)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := scanJobs(rows)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+
+	args = []any{c.worker, c.until}
+	for i := range jobs {
+		args = append(args, jobs[i].id)
+		jobs[i].attempts++
+	}
+	_, err = tx.ExecContext(ctx, d.rebind(
+		"update "+Table+" set locked_by = ?, locked_until = ?, attempts = attempts + 1"+
+			" where id in ("+placeholders(len(jobs))+")"),
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	return jobs, tx.Commit()
+}
+
+func (lockingDialect) leasedBy() string { return "locked_by = ?" }
+
+func (d lockingDialect) extend(ctx context.Context, db *sql.DB, worker string, ids []int64, until time.Time) error {
+	args := []any{until, worker}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	_, err := db.ExecContext(ctx, d.rebind(
+		"update "+Table+" set locked_until = ? where locked_by = ? and id in ("+placeholders(len(ids))+")"),
+		args...)
+	return err
+}
+
+func (d lockingDialect) release(ctx context.Context, db *sql.DB, worker string, id int64) error {
+	_, err := db.ExecContext(ctx, d.rebind(
+		"update "+Table+" set locked_by = null, locked_until = null where id = ? and locked_by = ?"),
+		id, worker)
+	return err
+}
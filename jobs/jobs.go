@@ -0,0 +1,250 @@
+// Package jobs is a durable background job queue stored in a SQL table.
+//
+// Jobs are typed: a job is a struct implementing Job, registered with a
+// handler on a Client. Enqueued jobs are stored as JSON and run by workers
+// in any process sharing the database, with retries and exponential
+// backoff for failed jobs.
+//
+//	type WelcomeEmail struct{ UserID uint }
+//
+//	func (WelcomeEmail) Kind() string { return "welcome_email" }
+//
+//	client := jobs.New(sqlDB, jobs.Config{Dialect: jobs.SQLite})
+//	jobs.Register(client, func(ctx context.Context, job WelcomeEmail) error {
+//		return sendWelcomeEmail(ctx, job.UserID)
+//	})
+//	client.Enqueue(ctx, WelcomeEmail{UserID: 1}, jobs.EnqueueOptions{RunAt: time.Now().Add(time.Hour)})
+//
+// Client.Run works the queue until its context is cancelled, which makes it
+// fit for kit.ServeConfig.Background.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/superkit/db"
+)
+
+// DefaultQueue is the queue of jobs enqueued without a queue name.
+const DefaultQueue = "default"
+
+// Job is the payload of a background job. Kind names the type of job; it is
+// stored with the job and selects its handler. Kind must not depend on the
+// fields of the job, since it is also called on the zero value.
+type Job interface {
+	Kind() string
+}
+
+// ErrDuplicate is returned by Enqueue when a pending or running job has the
+// same unique key.
+var ErrDuplicate = errors.New("jobs: duplicate unique key")
+
+// errLeaseLost is returned by finish when the reservation of a job expired
+// and another worker took it over.
+var errLeaseLost = errors.New("jobs: lease lost to another worker")
+
+// Querier runs statements. *sql.DB, *sql.Tx and gorm's ConnPool (for example
+// tx.Statement.ConnPool inside a gorm transaction) implement it.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Config configures a Client.
+type Config struct {
+	// Dialect adapts queries to the database. Defaults to SQLite.
+	Dialect Dialect
+	// Queues are the queues Run works. Defaults to the default queue.
+	Queues []string
+	// Concurrency is the number of jobs Run executes at a time. Defaults
+	// to 10.
+	Concurrency int
+	// PollInterval is how often Run looks for due jobs while idle.
+	// Defaults to one second.
+	PollInterval time.Duration
+	// LeaseTimeout is how long a job stays reserved for a worker. Running
+	// jobs renew their lease; jobs of a worker that died are picked up by
+	// another one once it expires. Defaults to five minutes.
+	LeaseTimeout time.Duration
+	// MaxAttempts is the default number of attempts of a job before it is
+	// marked as failed. Defaults to 25.
+	MaxAttempts int
+	// Backoff returns the delay before retrying a job after the given
+	// failed attempt. Defaults to DefaultBackoff.
+	Backoff func(attempt int) time.Duration
+	// ShutdownTimeout is how long Run waits for running jobs once its
+	// context is cancelled before cancelling theirs. Defaults to 30 seconds.
+	ShutdownTimeout time.Duration
+}
+
+// EnqueueOptions control how and when a job runs.
+type EnqueueOptions struct {
+	// RunAt delays the job until the given time. Defaults to now.
+	RunAt time.Time
+	// Priority orders due jobs; higher runs first.
+	Priority int
+	// Queue is the queue of the job. Defaults to DefaultQueue.
+	Queue string
+	// MaxAttempts overrides Config.MaxAttempts for the job.
+	MaxAttempts int
+	// UniqueKey prevents enqueueing the job while another pending or
+	// running job has the same key; Enqueue returns ErrDuplicate instead.
+	UniqueKey string
+}
+
+// Client enqueues jobs and runs the handlers registered on it.
+type Client struct {
+	db  *sql.DB
+	cfg Config
+
+	// worker identifies the reservations of this client
+	worker string
+
+	mu       sync.RWMutex
+	handlers map[string]handler
+}
+
+type handler func(ctx context.Context, payload []byte) error
+
+// New returns a Client storing jobs in conn.
+func New(conn *sql.DB, cfg Config) *Client {
+	if cfg.Dialect == nil {
+		cfg.Dialect = SQLite
+	}
+	if len(cfg.Queues) == 0 {
+		cfg.Queues = []string{DefaultQueue}
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 10
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.LeaseTimeout <= 0 {
+		cfg.LeaseTimeout = 5 * time.Minute
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 25
+	}
+	if cfg.Backoff == nil {
+		cfg.Backoff = DefaultBackoff
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = 30 * time.Second
+	}
+	return &Client{
+		db:       conn,
+		cfg:      cfg,
+		worker:   db.OwnerID(),
+		handlers: make(map[string]handler),
+	}
+}
+
+// Register registers h as the handler of jobs of type T. Registering a
+// second handler for the same kind replaces the first.
+func Register[T Job](c *Client, h func(ctx context.Context, job T) error) {
+	var zero T
+	kind := zero.Kind()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers[kind] = func(ctx context.Context, payload []byte) error {
+		var job T
+		if err := json.Unmarshal(payload, &job); err != nil {
+			return Permanent(fmt.Errorf("jobs: decode %s: %w", kind, err))
+		}
+		return h(ctx, job)
+	}
+}
+
+// Enqueue stores job to be run by a worker and returns its ID.
+func (c *Client) Enqueue(ctx context.Context, job Job, opts EnqueueOptions) (int64, error) {
+	return c.EnqueueTx(ctx, c.db, job, opts)
+}
+
+// EnqueueTx stores job using tx, so the job is enqueued if and only if the
+// surrounding transaction commits.
+func (c *Client) EnqueueTx(ctx context.Context, tx Querier, job Job, opts EnqueueOptions) (int64, error) {
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return 0, fmt.Errorf("jobs: encode %s: %w", job.Kind(), err)
+	}
+	if opts.Queue == "" {
+		opts.Queue = DefaultQueue
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = c.cfg.MaxAttempts
+	}
+	now := time.Now().UTC()
+	if opts.RunAt.IsZero() {
+		opts.RunAt = now
+	}
+	var uniqueKey any
+	if opts.UniqueKey != "" {
+		uniqueKey = opts.UniqueKey
+	}
+
+	d := c.cfg.Dialect
+	query := d.rebind("insert into "+Table+
+		" (queue, kind, payload, priority, attempts, max_attempts, unique_key, run_at, created_at)"+
+		" values (?, ?, ?, ?, 0, ?, ?, ?, ?)") + d.onConflict()
+	args := []any{opts.Queue, job.Kind(), string(payload), opts.Priority, opts.MaxAttempts, uniqueKey, opts.RunAt.UTC(), now}
+
+	if d.returnsID() {
+		var id int64
+		err := tx.QueryRowContext(ctx, query, args...).Scan(&id)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrDuplicate
+		}
+		return id, err
+	}
+	res, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, ErrDuplicate
+	}
+	return res.LastInsertId()
+}
+
+// DefaultBackoff waits 10 seconds after the first failed attempt, doubling
+// with every further attempt up to six hours, with 20% jitter so retries of
+// jobs that failed together spread out.
+func DefaultBackoff(attempt int) time.Duration {
+	d := 10 * time.Second
+	for i := 1; i < attempt && d < 6*time.Hour; i++ {
+		d *= 2
+	}
+	d = min(d, 6*time.Hour)
+	jitter := time.Duration(float64(d) * 0.2 * (2*rand.Float64() - 1))
+	return d + jitter
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as permanent: the job is marked as failed straight
+// away instead of being retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
@@ -0,0 +1,132 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type welcomeEmail struct {
+	UserID int
+}
+
+func (welcomeEmail) Kind() string { return "welcome_email" }
+
+type recordingQuerier struct {
+	query    string
+	args     []any
+	affected int64
+}
+
+func (q *recordingQuerier) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	q.query = query
+	q.args = args
+	return insertResult{id: 7, affected: q.affected}, nil
+}
+
+func (q *recordingQuerier) QueryRowContext(context.Context, string, ...any) *sql.Row {
+	panic("not used by sqlite")
+}
+
+type insertResult struct{ id, affected int64 }
+
+func (r insertResult) LastInsertId() (int64, error) { return r.id, nil }
+func (r insertResult) RowsAffected() (int64, error) { return r.affected, nil }
+
+func TestEnqueueTx(t *testing.T) {
+	c := New(nil, Config{})
+	q := &recordingQuerier{affected: 1}
+	runAt := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	id, err := c.EnqueueTx(context.Background(), q, welcomeEmail{UserID: 1}, EnqueueOptions{
+		RunAt:     runAt,
+		Priority:  5,
+		UniqueKey: "welcome:1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != 7 {
+		t.Fatalf("expected id 7 got %d", id)
+	}
+	if !strings.HasPrefix(q.query, "insert into jobs") || !strings.HasSuffix(q.query, "on conflict (unique_key) do nothing") {
+		t.Fatalf("unexpected query %q", q.query)
+	}
+	want := []any{DefaultQueue, "welcome_email", `{"UserID":1}`, 5, 25, "welcome:1", runAt}
+	for i, v := range want {
+		if q.args[i] != v {
+			t.Fatalf("arg %d: expected %v got %v", i, v, q.args[i])
+		}
+	}
+
+	q.affected = 0
+	if _, err := c.EnqueueTx(context.Background(), q, welcomeEmail{UserID: 1}, EnqueueOptions{UniqueKey: "welcome:1"}); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("expected ErrDuplicate got %v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	c := New(nil, Config{})
+	var got welcomeEmail
+	Register(c, func(_ context.Context, job welcomeEmail) error {
+		got = job
+		return nil
+	})
+	h := c.handlers["welcome_email"]
+	if err := runHandler(context.Background(), h, jobRow{kind: "welcome_email", payload: `{"UserID":3}`}); err != nil {
+		t.Fatal(err)
+	}
+	if got.UserID != 3 {
+		t.Fatalf("expected user 3 got %+v", got)
+	}
+
+	// undecodable payloads are not retried
+	err := runHandler(context.Background(), h, jobRow{kind: "welcome_email", payload: `{`})
+	if !IsPermanent(err) {
+		t.Fatalf("expected permanent error got %v", err)
+	}
+}
+
+func TestRunHandlerPanic(t *testing.T) {
+	h := func(context.Context, []byte) error { panic("boom") }
+	err := runHandler(context.Background(), h, jobRow{kind: "boom"})
+	if err == nil || !strings.Contains(err.Error(), "panicked: boom") {
+		t.Fatalf("expected panic error got %v", err)
+	}
+	if IsPermanent(err) {
+		t.Fatal("expected panics to be retried")
+	}
+}
+
+func TestDefaultBackoff(t *testing.T) {
+	tests := map[int]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		5:  160 * time.Second,
+		30: 6 * time.Hour,
+	}
+	for attempt, base := range tests {
+		d := DefaultBackoff(attempt)
+		if d < base*8/10 || d > base*12/10 {
+			t.Errorf("attempt %d: expected about %v got %v", attempt, base, d)
+		}
+	}
+}
+
+func TestDialectRebind(t *testing.T) {
+	got := Postgres.rebind("update jobs set a = ? where id in (?, ?)")
+	if want := "update jobs set a = $1 where id in ($2, $3)"; got != want {
+		t.Fatalf("expected %q got %q", want, got)
+	}
+	if got := MySQL.rebind("a = ?"); got != "a = ?" {
+		t.Fatalf("expected mysql placeholders to be kept, got %q", got)
+	}
+}
+
+func TestRunWithoutHandlers(t *testing.T) {
+	if err := New(nil, Config{}).Run(context.Background()); err == nil {
+		t.Fatal("expected error without handlers")
+	}
+}
@@ -0,0 +1,249 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "jobs.db")+"?_busy_timeout=5000&_txlock=immediate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(SQLiteSchema); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+type jobState struct {
+	attempts   int
+	finished   bool
+	failed     bool
+	lastError  sql.NullString
+	leasedBy   sql.NullString
+	finishedAt sql.NullTime
+}
+
+func readJob(t *testing.T, db *sql.DB, id int64) jobState {
+	t.Helper()
+	var (
+		s      jobState
+		failed sql.NullTime
+	)
+	err := db.QueryRow("select j.attempts, j.last_error, j.finished_at, j.failed_at, l.worker"+
+		" from jobs j left join job_leases l on l.job_id = j.id where j.id = ?", id).
+		Scan(&s.attempts, &s.lastError, &s.finishedAt, &failed, &s.leasedBy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.finished, s.failed = s.finishedAt.Valid, failed.Valid
+	return s
+}
+
+func TestSQLiteClaim(t *testing.T) {
+	db := openSQLite(t)
+	c := New(db, Config{})
+	ctx := context.Background()
+	due, err := c.Enqueue(ctx, welcomeEmail{UserID: 1}, EnqueueOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Enqueue(ctx, welcomeEmail{UserID: 2}, EnqueueOptions{RunAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	cl := claim{worker: "a", queues: []string{DefaultQueue}, kinds: []string{"welcome_email"}, now: now, until: now.Add(time.Minute), limit: 10}
+	jobs, err := SQLite.claim(ctx, db, cl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].id != due || jobs[0].attempts != 1 {
+		t.Fatalf("expected the due job with one attempt, got %+v", jobs)
+	}
+	if s := readJob(t, db, due); s.attempts != 1 || s.leasedBy.String != "a" {
+		t.Fatalf("expected the job leased to a with one attempt, got %+v", s)
+	}
+
+	// a live lease keeps other workers out
+	cl.worker = "b"
+	if jobs, err := SQLite.claim(ctx, db, cl); err != nil || len(jobs) != 0 {
+		t.Fatalf("expected no jobs while leased, got %+v %v", jobs, err)
+	}
+
+	// an expired lease is taken over
+	cl.now, cl.until = now.Add(2*time.Minute), now.Add(3*time.Minute)
+	if jobs, err := SQLite.claim(ctx, db, cl); err != nil || len(jobs) != 1 || jobs[0].attempts != 2 {
+		t.Fatalf("expected the job to be taken over, got %+v %v", jobs, err)
+	}
+
+	// a finished job is not leased again, even if it was selected before
+	// it finished
+	if _, err := db.Exec("update jobs set finished_at = ? where id = ?", now, due); err != nil {
+		t.Fatal(err)
+	}
+	cl.now, cl.until = now.Add(4*time.Minute), now.Add(5*time.Minute)
+	ok, err := sqliteDialect{}.lease(ctx, db, cl, due)
+	if err != nil || ok {
+		t.Fatalf("expected the finished job not to be leased, got %v %v", ok, err)
+	}
+	if s := readJob(t, db, due); s.attempts != 2 {
+		t.Fatalf("expected no attempt to be counted, got %d", s.attempts)
+	}
+}
+
+func TestSQLiteFinishAfterLeaseLost(t *testing.T) {
+	db := openSQLite(t)
+	a, b := New(db, Config{}), New(db, Config{})
+	ctx := context.Background()
+	id, err := a.Enqueue(ctx, welcomeEmail{UserID: 1}, EnqueueOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	cl := claim{worker: a.worker, queues: []string{DefaultQueue}, kinds: []string{"welcome_email"}, now: now, until: now.Add(time.Minute), limit: 1}
+	jobs, err := SQLite.claim(ctx, db, cl)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("expected a to claim the job, got %+v %v", jobs, err)
+	}
+	// a's lease expires and b takes the job over
+	cl.worker, cl.now, cl.until = b.worker, now.Add(2*time.Minute), now.Add(3*time.Minute)
+	if taken, err := SQLite.claim(ctx, db, cl); err != nil || len(taken) != 1 {
+		t.Fatalf("expected b to take the job over, got %+v %v", taken, err)
+	}
+
+	if err := a.finish(ctx, jobs[0], errors.New("late failure")); !errors.Is(err, errLeaseLost) {
+		t.Fatalf("expected errLeaseLost got %v", err)
+	}
+	if s := readJob(t, db, id); s.lastError.Valid || s.leasedBy.String != b.worker {
+		t.Fatalf("expected the job to be left to b, got %+v", s)
+	}
+}
+
+func TestSQLiteRun(t *testing.T) {
+	db := openSQLite(t)
+	c := New(db, Config{
+		PollInterval: 10 * time.Millisecond,
+		Backoff:      func(int) time.Duration { return 0 },
+	})
+	var calls atomic.Int32
+	done := make(chan struct{})
+	Register(c, func(_ context.Context, job welcomeEmail) error {
+		switch job.UserID {
+		case 1:
+			// fails once, then succeeds
+			if calls.Add(1) == 1 {
+				return errors.New("smtp unavailable")
+			}
+			close(done)
+			return nil
+		default:
+			return Permanent(errors.New("no such user"))
+		}
+	})
+	ctx := context.Background()
+	retried, err := c.Enqueue(ctx, welcomeEmail{UserID: 1}, EnqueueOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed, err := c.Enqueue(ctx, welcomeEmail{UserID: 2}, EnqueueOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		c.Run(runCtx)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job did not succeed")
+	}
+	cancel()
+	<-stopped
+
+	if s := readJob(t, db, retried); !s.finished || s.attempts != 2 || s.lastError.Valid || s.leasedBy.Valid {
+		t.Errorf("expected the job to finish on the second attempt, got %+v", s)
+	}
+	if s := readJob(t, db, failed); !s.failed || s.attempts != 1 || s.lastError.String != "no such user" || s.leasedBy.Valid {
+		t.Errorf("expected the job to fail without retries, got %+v", s)
+	}
+}
+
+func TestSQLiteConcurrentWorkers(t *testing.T) {
+	db := openSQLite(t)
+	const n = 50
+	var (
+		mu   sync.Mutex
+		runs = make(map[int]int)
+		wg   sync.WaitGroup
+	)
+	wg.Add(n)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var stopped sync.WaitGroup
+	for range 2 {
+		c := New(db, Config{PollInterval: 5 * time.Millisecond, Concurrency: 4})
+		Register(c, func(_ context.Context, job welcomeEmail) error {
+			mu.Lock()
+			runs[job.UserID]++
+			mu.Unlock()
+			wg.Done()
+			return nil
+		})
+		stopped.Add(1)
+		go func() {
+			defer stopped.Done()
+			c.Run(ctx)
+		}()
+	}
+	enqueuer := New(db, Config{})
+	for i := range n {
+		if _, err := enqueuer.Enqueue(context.Background(), welcomeEmail{UserID: i}, EnqueueOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(10 * time.Second):
+		t.Fatal("jobs did not finish")
+	}
+	cancel()
+	stopped.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for id, count := range runs {
+		if count != 1 {
+			t.Errorf("job of user %d ran %d times", id, count)
+		}
+	}
+	var pending int
+	if err := db.QueryRow("select count(*) from jobs where finished_at is null").Scan(&pending); err != nil {
+		t.Fatal(err)
+	}
+	if pending != 0 {
+		t.Errorf("expected every job to be finished, %d pending", pending)
+	}
+}
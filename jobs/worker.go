@@ -0,0 +1,245 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Run works the configured queues until ctx is cancelled. It reserves due
+// jobs of the registered kinds, runs up to Config.Concurrency of them at a
+// time and records the outcome: failed jobs are retried after
+// Config.Backoff until they run out of attempts.
+//
+// Once ctx is cancelled Run stops reserving jobs and waits up to
+// Config.ShutdownTimeout for running jobs, then cancels their context and
+// waits for them to return.
+func (c *Client) Run(ctx context.Context) error {
+	c.mu.RLock()
+	kinds := make([]string, 0, len(c.handlers))
+	for kind := range c.handlers {
+		kinds = append(kinds, kind)
+	}
+	c.mu.RUnlock()
+	if len(kinds) == 0 {
+		return errors.New("jobs: no handlers registered")
+	}
+	slices.Sort(kinds)
+
+	// Jobs keep running after ctx is cancelled until the shutdown timeout.
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	w := &worker{
+		c:       c,
+		kinds:   kinds,
+		slots:   make(chan struct{}, c.cfg.Concurrency),
+		freed:   make(chan struct{}, 1),
+		running: make(map[int64]struct{}),
+	}
+	stopHeartbeat := make(chan struct{})
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		w.heartbeat(stopHeartbeat)
+	}()
+
+	ticker := time.NewTicker(c.cfg.PollInterval)
+	defer ticker.Stop()
+poll:
+	for {
+		if free := cap(w.slots) - len(w.slots); free > 0 {
+			jobs, err := w.claim(ctx, free)
+			if err != nil && ctx.Err() == nil {
+				slog.Error("jobs: reserving jobs failed", "err", err)
+			}
+			for _, job := range jobs {
+				w.start(jobCtx, job)
+			}
+			// more jobs may be due
+			if err == nil && len(jobs) == free {
+				continue
+			}
+		}
+		select {
+		case <-ctx.Done():
+			break poll
+		case <-ticker.C:
+		case <-w.freed:
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(c.cfg.ShutdownTimeout):
+		slog.Warn("jobs: cancelling running jobs after shutdown timeout")
+		cancelJobs()
+		<-done
+	}
+	close(stopHeartbeat)
+	<-heartbeatDone
+	return nil
+}
+
+// worker is the state of a Run call.
+type worker struct {
+	c     *Client
+	kinds []string
+
+	// slots limits the running jobs to Config.Concurrency
+	slots chan struct{}
+	// freed is signalled when a job finished, so due jobs are reserved
+	// without waiting for the next poll
+	freed chan struct{}
+	wg    sync.WaitGroup
+
+	mu      sync.Mutex
+	running map[int64]struct{}
+}
+
+func (w *worker) claim(ctx context.Context, limit int) ([]jobRow, error) {
+	now := time.Now().UTC()
+	return w.c.cfg.Dialect.claim(ctx, w.c.db, claim{
+		worker: w.c.worker,
+		queues: w.c.cfg.Queues,
+		kinds:  w.kinds,
+		now:    now,
+		until:  now.Add(w.c.cfg.LeaseTimeout),
+		limit:  limit,
+	})
+}
+
+func (w *worker) start(ctx context.Context, job jobRow) {
+	w.slots <- struct{}{}
+	w.wg.Add(1)
+	w.mu.Lock()
+	w.running[job.id] = struct{}{}
+	w.mu.Unlock()
+	go func() {
+		defer func() {
+			w.mu.Lock()
+			delete(w.running, job.id)
+			w.mu.Unlock()
+			<-w.slots
+			select {
+			case w.freed <- struct{}{}:
+			default:
+			}
+			w.wg.Done()
+		}()
+		w.c.execute(ctx, job)
+	}()
+}
+
+// heartbeat renews the leases of running jobs until stop is closed.
+func (w *worker) heartbeat(stop <-chan struct{}) {
+	ticker := time.NewTicker(w.c.cfg.LeaseTimeout / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		w.mu.Lock()
+		ids := make([]int64, 0, len(w.running))
+		for id := range w.running {
+			ids = append(ids, id)
+		}
+		w.mu.Unlock()
+		if len(ids) == 0 {
+			continue
+		}
+		until := time.Now().UTC().Add(w.c.cfg.LeaseTimeout)
+		if err := w.c.cfg.Dialect.extend(context.Background(), w.c.db, w.c.worker, ids, until); err != nil {
+			slog.Error("jobs: renewing leases failed", "err", err)
+		}
+	}
+}
+
+// execute runs the handler of job and records the outcome.
+func (c *Client) execute(ctx context.Context, job jobRow) {
+	c.mu.RLock()
+	h, ok := c.handlers[job.kind]
+	c.mu.RUnlock()
+
+	start := time.Now()
+	var err error
+	if !ok {
+		err = Permanent(fmt.Errorf("jobs: no handler for %s", job.kind))
+	} else {
+		err = runHandler(ctx, h, job)
+	}
+	// Record the outcome even if the job was cancelled.
+	ctx = context.WithoutCancel(ctx)
+	if err := c.finish(ctx, job, err); errors.Is(err, errLeaseLost) {
+		slog.Warn("jobs: job outlived its lease and was taken over by another worker", "kind", job.kind, "id", job.id)
+	} else if err != nil {
+		slog.Error("jobs: recording job outcome failed", "kind", job.kind, "id", job.id, "err", err)
+	}
+	if err != nil {
+		slog.Error("jobs: job failed", "kind", job.kind, "id", job.id,
+			"attempt", job.attempts, "max_attempts", job.maxAttempts, "err", err)
+		return
+	}
+	slog.Debug("jobs: job done", "kind", job.kind, "id", job.id, "duration", time.Since(start))
+}
+
+// runHandler calls h, turning a panic into an error.
+func runHandler(ctx context.Context, h handler, job jobRow) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("jobs: job panicked", "kind", job.kind, "id", job.id, "stack", string(debug.Stack()))
+			err = fmt.Errorf("jobs: %s panicked: %v", job.kind, r)
+		}
+	}()
+	return h(ctx, []byte(job.payload))
+}
+
+// finish records the outcome of an attempt of job and releases it. The
+// outcome is only recorded while the client still holds the reservation; if
+// it expired and another worker took the job over, finish returns
+// errLeaseLost and leaves the job to that worker.
+func (c *Client) finish(ctx context.Context, job jobRow, jobErr error) error {
+	d := c.cfg.Dialect
+	now := time.Now().UTC()
+	leased := " where id = ? and " + d.leasedBy()
+	var (
+		res sql.Result
+		err error
+	)
+	switch {
+	case jobErr == nil:
+		res, err = c.db.ExecContext(ctx, d.rebind(
+			"update "+Table+" set finished_at = ?, unique_key = null, last_error = null"+leased),
+			now, job.id, c.worker)
+	case job.attempts >= job.maxAttempts || IsPermanent(jobErr):
+		res, err = c.db.ExecContext(ctx, d.rebind(
+			"update "+Table+" set failed_at = ?, unique_key = null, last_error = ?"+leased),
+			now, jobErr.Error(), job.id, c.worker)
+	default:
+		res, err = c.db.ExecContext(ctx, d.rebind(
+			"update "+Table+" set run_at = ?, last_error = ?"+leased),
+			now.Add(c.cfg.Backoff(job.attempts)), jobErr.Error(), job.id, c.worker)
+	}
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return errLeaseLost
+	}
+	return d.release(ctx, c.db, c.worker, job.id)
+}
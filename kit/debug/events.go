@@ -0,0 +1,59 @@
+// Package debug provides kit handlers exposing the internals of a running
+// application, such as the state of the event bus.
+package debug
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/khulnasoft/superkit/event"
+	"github.com/khulnasoft/superkit/kit"
+)
+
+// EventsConfig configures Events.
+type EventsConfig struct {
+	// Bus is the bus to report on. Defaults to the default bus.
+	Bus *event.Bus
+	// Token must be presented as a bearer token in the Authorization header
+	// outside development. An empty token rejects every request
+	// outside development, so the endpoint is never exposed by accident.
+	Token string
+}
+
+// Events returns a handler serving the statistics of the event bus: the
+// subscribers per topic, the emitted, dropped and failed counters, the queue
+// depth and handler latency percentiles.
+//
+// Requests asking for JSON, through the Accept header or ?format=json, get
+// event.BusStats as JSON. In development other requests get a dashboard page
+// that refreshes itself; elsewhere JSON is always served.
+//
+//	router.Get("/debug/events", kit.Handler(debug.Events(debug.EventsConfig{
+//		Token: os.Getenv("DEBUG_TOKEN"),
+//	})))
+func Events(cfg EventsConfig) kit.HandlerFunc {
+	return func(k *kit.Kit) error {
+		dev := kit.IsDevelopment()
+		if !dev && !kit.ValidBearerToken(k.Request, cfg.Token) {
+			k.Response.Header().Set("WWW-Authenticate", `Bearer realm="debug"`)
+			return k.Text(http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+		}
+		bus := cfg.Bus
+		if bus == nil {
+			bus = event.Default()
+		}
+		stats := bus.Stats()
+		if !dev || wantsJSON(k.Request) {
+			return k.JSON(http.StatusOK, stats)
+		}
+		k.Response.Header().Set("Content-Type", "text/html; charset=utf-8")
+		return k.Render(eventsPage(stats))
+	}
+}
+
+func wantsJSON(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
@@ -0,0 +1,76 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/khulnasoft/superkit/event"
+	"github.com/khulnasoft/superkit/kit"
+)
+
+func newBus(t *testing.T) *event.Bus {
+	t.Helper()
+	b := event.New(event.Config{})
+	t.Cleanup(b.Stop)
+	b.Subscribe("debug.ping", func(context.Context, any) {})
+	if err := b.EmitAndWait(context.Background(), "debug.ping", 1); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestEventsJSON(t *testing.T) {
+	t.Setenv("SUPERKIT_ENV", "production")
+	h := kit.Handler(Events(EventsConfig{Bus: newBus(t), Token: "secret"}))
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/debug/events", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without token, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/debug/events?token=secret", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 with the token in the query, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/debug/events", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	h(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var stats event.BusStats
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats.Topics) != 1 || stats.Topics[0].Topic != "debug.ping" || stats.Topics[0].Handled != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestEventsDashboard(t *testing.T) {
+	t.Setenv("SUPERKIT_ENV", "development")
+	h := kit.Handler(Events(EventsConfig{Bus: newBus(t)}))
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/debug/events", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("expected html, got %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "debug.ping") {
+		t.Fatal("expected topic in dashboard")
+	}
+
+	rec = httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/debug/events?format=json", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("expected json, got %q", ct)
+	}
+}
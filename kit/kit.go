@@ -32,10 +32,36 @@ type Auth interface {
 
 var (
 	errorHandler = func(kit *Kit, err error) {
-		kit.Text(http.StatusInternalServerError, err.Error())
+		kit.Text(StatusCode(err), err.Error())
 	}
 )
 
+// StatusError is an error that should be reported to the client with a
+// specific HTTP status code instead of a 500.
+type StatusError struct {
+	Code int
+	Err  error
+}
+
+func (e *StatusError) Error() string {
+	if e.Err == nil {
+		return http.StatusText(e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error { return e.Err }
+
+// StatusCode returns the HTTP status code carried by err if it wraps a
+// *StatusError, otherwise http.StatusInternalServerError.
+func StatusCode(err error) int {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Code
+	}
+	return http.StatusInternalServerError
+}
+
 type DefaultAuth struct{}
 
 func (DefaultAuth) Check() bool { return false }
@@ -132,14 +158,22 @@ func (kit *Kit) Getenv(name string, def string) string {
 }
 
 // BindJSON decodes the request body into v. It disallows unknown fields to help
-// catch client mistakes early.
+// catch client mistakes early. A body exceeding the limit set by
+// middleware.WithMaxBodySize is reported as a *StatusError with code 413.
 func (kit *Kit) BindJSON(v any) error {
 	if kit.Request.Body == nil {
 		return errors.New("request body is empty")
 	}
 	dec := json.NewDecoder(kit.Request.Body)
 	dec.DisallowUnknownFields()
-	return dec.Decode(v)
+	if err := dec.Decode(v); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return &StatusError{Code: http.StatusRequestEntityTooLarge, Err: err}
+		}
+		return err
+	}
+	return nil
 }
 
 // Query returns the given query parameter or a default if missing.
@@ -163,7 +197,7 @@ func Handler(h HandlerFunc) http.HandlerFunc {
 				return
 			}
 			// fallback
-			_ = kit.Text(http.StatusInternalServerError, err.Error())
+			_ = kit.Text(StatusCode(err), err.Error())
 		}
 	}
 }
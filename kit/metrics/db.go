@@ -0,0 +1,35 @@
+package metrics
+
+import (
+	"database/sql"
+	"io"
+)
+
+// InstrumentDB registers a collector exporting the database/sql pool
+// statistics of db. The statistics are read at scrape time. Name is used as
+// the metric prefix, for example "db" results in db_open_connections.
+func InstrumentDB(reg *Registry, name string, db *sql.DB) {
+	reg.Register(&dbCollector{name: name, db: db})
+}
+
+type dbCollector struct {
+	name string
+	db   *sql.DB
+}
+
+func (c *dbCollector) Name() string { return c.name }
+
+func (c *dbCollector) Collect(w io.Writer) error {
+	s := c.db.Stats()
+	p := c.name + "_"
+	WriteFamily(w, p+"max_open_connections", "gauge", "Maximum number of open connections to the database.", float64(s.MaxOpenConnections))
+	WriteFamily(w, p+"open_connections", "gauge", "Number of established connections, both in use and idle.", float64(s.OpenConnections))
+	WriteFamily(w, p+"in_use_connections", "gauge", "Number of connections currently in use.", float64(s.InUse))
+	WriteFamily(w, p+"idle_connections", "gauge", "Number of idle connections.", float64(s.Idle))
+	WriteFamily(w, p+"wait_count_total", "counter", "Total number of connections waited for.", float64(s.WaitCount))
+	WriteFamily(w, p+"wait_duration_seconds_total", "counter", "Total time blocked waiting for a new connection.", s.WaitDuration.Seconds())
+	WriteFamily(w, p+"max_idle_closed_total", "counter", "Total number of connections closed due to SetMaxIdleConns.", float64(s.MaxIdleClosed))
+	WriteFamily(w, p+"max_idle_time_closed_total", "counter", "Total number of connections closed due to SetConnMaxIdleTime.", float64(s.MaxIdleTimeClosed))
+	WriteFamily(w, p+"max_lifetime_closed_total", "counter", "Total number of connections closed due to SetConnMaxLifetime.", float64(s.MaxLifetimeClosed))
+	return nil
+}
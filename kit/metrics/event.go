@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/khulnasoft/superkit/event"
+)
+
+// InstrumentEvents registers metrics for the event stream in reg and installs
+// an event.Observer that keeps them up to date.
+func InstrumentEvents(reg *Registry) {
+	event.Observe(&eventObserver{
+		emitted: reg.Counter("events_emitted_total",
+			"Total number of events emitted by topic.", "topic"),
+		dropped: reg.Counter("events_dropped_total",
+			"Total number of events dropped by topic.", "topic"),
+		inFlight: reg.Gauge("event_handlers_in_flight",
+			"Number of event handlers currently running by topic.", "topic"),
+		duration: reg.Histogram("event_handler_duration_seconds",
+			"Duration of event handler invocations in seconds by topic.", nil, "topic"),
+	})
+}
+
+type eventObserver struct {
+	emitted  *Counter
+	dropped  *Counter
+	inFlight *Gauge
+	duration *Histogram
+}
+
+func (o *eventObserver) Emitted(topic string)        { o.emitted.Inc(topic) }
+func (o *eventObserver) Dropped(topic string)        { o.dropped.Inc(topic) }
+func (o *eventObserver) HandlerStarted(topic string) { o.inFlight.Inc(topic) }
+
+func (o *eventObserver) HandlerFinished(topic string, d time.Duration) {
+	o.inFlight.Dec(topic)
+	o.duration.Observe(d.Seconds(), topic)
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/khulnasoft/superkit/kit"
+	"github.com/khulnasoft/superkit/kit/middleware"
+)
+
+// MiddlewareConfig configures the HTTP metrics middleware.
+type MiddlewareConfig struct {
+	// RouteFunc returns the route pattern a request was matched against. It is
+	// called after the handler ran. Using patterns instead of raw paths keeps
+	// the number of series bounded. With chi:
+	//
+	//	RouteFunc: func(r *http.Request) string {
+	//		return chi.RouteContext(r.Context()).RoutePattern()
+	//	}
+	//
+	// Defaults to the pattern set by http.ServeMux.
+	RouteFunc func(*http.Request) string
+	// Buckets used for the request duration histogram. Defaults to DefaultBuckets.
+	Buckets []float64
+}
+
+// Middleware returns HTTP middleware that records the number of requests per
+// method, route and status, and their duration per method and route, in reg.
+func Middleware(reg *Registry, cfg MiddlewareConfig) func(http.Handler) http.Handler {
+	route := cfg.RouteFunc
+	if route == nil {
+		route = func(r *http.Request) string { return r.Pattern }
+	}
+	requests := reg.Counter("http_requests_total",
+		"Total number of HTTP requests by method, route and status code.",
+		"method", "route", "status")
+	duration := reg.Histogram("http_request_duration_seconds",
+		"Duration of HTTP requests in seconds by method and route.",
+		cfg.Buckets, "method", "route")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.WrapResponseWriter(w)
+			next.ServeHTTP(ww, r)
+
+			pattern := route(r)
+			if pattern == "" {
+				pattern = "unmatched"
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			requests.Inc(r.Method, pattern, strconv.Itoa(status))
+			duration.Observe(time.Since(start).Seconds(), r.Method, pattern)
+		})
+	}
+}
+
+// Handler returns an http.Handler serving the metrics in reg in the Prometheus
+// text format. Requests must present token as a bearer token in the
+// Authorization header. An empty token rejects every request so metrics are
+// never exposed by accident.
+func Handler(reg *Registry, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !kit.ValidBearerToken(r, token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = reg.WriteTo(w)
+	})
+}
@@ -0,0 +1,371 @@
+// Package metrics provides counters, gauges and histograms that are exposed in
+// the Prometheus text exposition format without any external dependencies.
+//
+//	reg := metrics.NewRegistry()
+//	signups := reg.Counter("signups_total", "Number of user signups.")
+//	signups.Inc()
+//	router.Handle("/metrics", metrics.Handler(reg, os.Getenv("METRICS_TOKEN")))
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram buckets used when none are given. They are
+// tailored to request and handler latencies measured in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is a registry applications can share instead of creating their own.
+var Default = NewRegistry()
+
+// Collector writes one or more metric families in the text exposition format.
+// Custom collectors can be registered to export values that are only computed
+// at scrape time.
+type Collector interface {
+	// Name returns the name the collector is sorted and deduplicated by.
+	Name() string
+	// Collect writes the collector's metric families to w.
+	Collect(w io.Writer) error
+}
+
+// Registry holds a set of collectors.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector)}
+}
+
+// Register adds c to the registry. It panics if a collector with the same
+// name is already registered.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.collectors[c.Name()]; ok {
+		panic(fmt.Sprintf("metrics: collector %q already registered", c.Name()))
+	}
+	r.collectors[c.Name()] = c
+}
+
+// Counter registers and returns a counter with the given label names.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, "counter", labels)}
+	r.Register(c)
+	return c
+}
+
+// Gauge registers and returns a gauge with the given label names.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, help, "gauge", labels)}
+	r.Register(g)
+	return g
+}
+
+// Histogram registers and returns a histogram with the given upper bounds and
+// label names. If buckets is nil DefaultBuckets is used.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = slices.Clone(buckets)
+	sort.Float64s(buckets)
+	h := &Histogram{vec: newVec(name, help, "histogram", labels), buckets: buckets}
+	r.Register(h)
+	return h
+}
+
+// WriteTo writes all registered metrics to w sorted by name.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	collectors := make([]Collector, len(names))
+	for i, name := range names {
+		collectors[i] = r.collectors[name]
+	}
+	r.mu.RUnlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range collectors {
+		if err := c.Collect(bw); err != nil {
+			return cw.n, err
+		}
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct{ vec }
+
+// Inc increments the counter for the given label values by 1.
+func (c *Counter) Inc(labelValues ...string) { c.Add(1, labelValues...) }
+
+// Add increments the counter for the given label values by v. Negative values
+// are ignored.
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	s := c.series(labelValues)
+	c.mu.Lock()
+	s.value += v
+	c.mu.Unlock()
+}
+
+// Value returns the current value for the given label values.
+func (c *Counter) Value(labelValues ...string) float64 { return c.value(labelValues) }
+
+// Collect implements Collector.
+func (c *Counter) Collect(w io.Writer) error { return c.collectValues(w) }
+
+// Gauge is a value that can go up and down.
+type Gauge struct{ vec }
+
+// Set sets the gauge for the given label values to v.
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	s := g.series(labelValues)
+	g.mu.Lock()
+	s.value = v
+	g.mu.Unlock()
+}
+
+// Add adds v, which may be negative, to the gauge for the given label values.
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	s := g.series(labelValues)
+	g.mu.Lock()
+	s.value += v
+	g.mu.Unlock()
+}
+
+// Inc increments the gauge for the given label values by 1.
+func (g *Gauge) Inc(labelValues ...string) { g.Add(1, labelValues...) }
+
+// Dec decrements the gauge for the given label values by 1.
+func (g *Gauge) Dec(labelValues ...string) { g.Add(-1, labelValues...) }
+
+// Value returns the current value for the given label values.
+func (g *Gauge) Value(labelValues ...string) float64 { return g.value(labelValues) }
+
+// Collect implements Collector.
+func (g *Gauge) Collect(w io.Writer) error { return g.collectValues(w) }
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct {
+	vec
+	buckets []float64
+}
+
+// Observe records v for the given label values.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	s := h.series(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets))
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+// Count returns the number of observations for the given label values.
+func (h *Histogram) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.all[seriesKey(labelValues)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+// Collect implements Collector.
+func (h *Histogram) Collect(w io.Writer) error {
+	h.writeHeader(w)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, s := range h.sorted() {
+		for i, upper := range h.buckets {
+			var n uint64
+			if s.counts != nil {
+				n = s.counts[i]
+			}
+			writeSample(w, h.name+"_bucket", h.labels, s.labelValues, "le", formatFloat(upper), float64(n))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, s.labelValues, "le", "+Inf", float64(s.count))
+		writeSample(w, h.name+"_sum", h.labels, s.labelValues, "", "", s.value)
+		writeSample(w, h.name+"_count", h.labels, s.labelValues, "", "", float64(s.count))
+	}
+	return nil
+}
+
+// series is a single labelled time series.
+type series struct {
+	labelValues []string
+	value       float64
+	// histogram only
+	counts []uint64
+	count  uint64
+}
+
+// vec holds all series of a metric family keyed by their label values.
+type vec struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu  sync.Mutex
+	all map[string]*series
+}
+
+func newVec(name, help, typ string, labels []string) vec {
+	return vec{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		all:    make(map[string]*series),
+	}
+}
+
+// Name implements Collector.
+func (v *vec) Name() string { return v.name }
+
+func (v *vec) series(labelValues []string) *series {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := seriesKey(labelValues)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	s, ok := v.all[key]
+	if !ok {
+		s = &series{labelValues: slices.Clone(labelValues)}
+		v.all[key] = s
+	}
+	return s
+}
+
+func (v *vec) value(labelValues []string) float64 {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.all[seriesKey(labelValues)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+// sorted returns the series ordered by label values. Callers hold v.mu.
+func (v *vec) sorted() []*series {
+	out := make([]*series, 0, len(v.all))
+	for _, s := range v.all {
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return slices.Compare(out[i].labelValues, out[j].labelValues) < 0
+	})
+	return out
+}
+
+func (v *vec) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, v.typ)
+}
+
+func (v *vec) collectValues(w io.Writer) error {
+	v.writeHeader(w)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, s := range v.sorted() {
+		writeSample(w, v.name, v.labels, s.labelValues, "", "", s.value)
+	}
+	return nil
+}
+
+// WriteFamily writes a single metric family with one unlabelled sample. It is
+// meant for custom collectors that compute their values at scrape time.
+func WriteFamily(w io.Writer, name, typ, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+	writeSample(w, name, nil, nil, "", "", value)
+}
+
+func writeSample(w io.Writer, name string, labels, values []string, extraLabel, extraValue string, v float64) {
+	var sb strings.Builder
+	sb.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		sb.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(&sb, "%s=\"%s\"", l, escapeLabel(values[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				sb.WriteByte(',')
+			}
+			fmt.Fprintf(&sb, "%s=\"%s\"", extraLabel, escapeLabel(extraValue))
+		}
+		sb.WriteByte('}')
+	}
+	sb.WriteByte(' ')
+	sb.WriteString(formatFloat(v))
+	sb.WriteByte('\n')
+	io.WriteString(w, sb.String())
+}
+
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/superkit/event"
+)
+
+func TestWriteTo(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.Counter("requests_total", "Total requests.", "path")
+	c.Inc("/a")
+	c.Add(2, "/b\"")
+	g := reg.Gauge("temperature", "Current temperature.")
+	g.Set(21.5)
+	h := reg.Histogram("latency_seconds", "Latency.", []float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(0.5)
+
+	var sb strings.Builder
+	if _, err := reg.WriteTo(&sb); err != nil {
+		t.Fatal(err)
+	}
+	expect := `# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="1"} 2
+latency_seconds_bucket{le="+Inf"} 2
+latency_seconds_sum 0.55
+latency_seconds_count 2
+# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{path="/a"} 1
+requests_total{path="/b\""} 2
+# HELP temperature Current temperature.
+# TYPE temperature gauge
+temperature 21.5
+`
+	if sb.String() != expect {
+		t.Errorf("unexpected output:\n%s", sb.String())
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	reg := NewRegistry()
+	reg.Counter("foo_total", "Foo.").Inc()
+
+	for _, tc := range []struct {
+		token  string
+		header string
+		query  string
+		status int
+	}{
+		{token: "secret", header: "Bearer secret", status: http.StatusOK},
+		{token: "secret", header: "Bearer wrong", status: http.StatusUnauthorized},
+		{token: "secret", header: "", status: http.StatusUnauthorized},
+		{token: "", header: "Bearer ", status: http.StatusUnauthorized},
+		{token: "secret", query: "?token=secret", status: http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics"+tc.query, nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		rec := httptest.NewRecorder()
+		Handler(reg, tc.token).ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("token %q header %q: expected %d got %d", tc.token, tc.header, tc.status, rec.Code)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	reg := NewRegistry()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	h := Middleware(reg, MiddlewareConfig{})(mux)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/2", nil))
+
+	var sb strings.Builder
+	reg.WriteTo(&sb)
+	if !strings.Contains(sb.String(), `http_requests_total{method="GET",route="GET /users/{id}",status="418"} 2`) {
+		t.Errorf("expected request counter by route, got:\n%s", sb.String())
+	}
+}
+
+func TestInstrumentEvents(t *testing.T) {
+	reg := NewRegistry()
+	InstrumentEvents(reg)
+	defer event.Observe(nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	event.Subscribe("metrics.test", func(_ context.Context, _ any) { cancel() })
+	event.Emit("metrics.test", 1)
+	<-ctx.Done()
+
+	var sb strings.Builder
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		sb.Reset()
+		reg.WriteTo(&sb)
+		if strings.Contains(sb.String(), `event_handler_duration_seconds_count{topic="metrics.test"} 1`) {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !strings.Contains(sb.String(), `events_emitted_total{topic="metrics.test"} 1`) {
+		t.Errorf("expected emitted counter, got:\n%s", sb.String())
+	}
+	if !strings.Contains(sb.String(), `event_handler_duration_seconds_count{topic="metrics.test"} 1`) {
+		t.Errorf("expected handler duration, got:\n%s", sb.String())
+	}
+}
@@ -0,0 +1,214 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AccessLogFormat selects the line format written by AccessLog.
+type AccessLogFormat int
+
+const (
+	// FormatCombined is the Apache/NGINX Combined Log Format.
+	FormatCombined AccessLogFormat = iota
+	// FormatLogfmt writes key=value pairs.
+	FormatLogfmt
+	// FormatJSON writes one JSON object per line.
+	FormatJSON
+)
+
+// ParseAccessLogFormat returns the format named by s ("combined", "logfmt"
+// or "json"). Unknown names fall back to FormatCombined.
+func ParseAccessLogFormat(s string) AccessLogFormat {
+	switch strings.ToLower(s) {
+	case "logfmt":
+		return FormatLogfmt
+	case "json":
+		return FormatJSON
+	default:
+		return FormatCombined
+	}
+}
+
+// AccessLogConfig configures AccessLog.
+type AccessLogConfig struct {
+	// Output receives one line per request.
+	Output io.Writer
+	// Format of the lines. Defaults to FormatCombined.
+	Format AccessLogFormat
+	// Exclude lists paths that are not logged. Entries ending in "*" match
+	// by prefix, for example "/public/*".
+	Exclude []string
+	// SampleRate is the fraction of requests logged, between 0 and 1. Zero
+	// logs every request. Server errors (5xx) are always logged.
+	SampleRate float64
+	// RouteFunc returns the route pattern the request matched. It is called
+	// after the handler ran. Defaults to the pattern set by http.ServeMux.
+	RouteFunc func(*http.Request) string
+	// RequestIDFunc returns the request ID. Defaults to the X-Request-Id
+	// request header.
+	RequestIDFunc func(*http.Request) string
+}
+
+// AccessLogEntry holds the fields recorded for a request.
+type AccessLogEntry struct {
+	Time       time.Time     `json:"time"`
+	RemoteAddr string        `json:"remote_addr"`
+	Method     string        `json:"method"`
+	Path       string        `json:"path"`
+	Proto      string        `json:"proto"`
+	Route      string        `json:"route,omitempty"`
+	Status     int           `json:"status"`
+	Bytes      int64         `json:"bytes"`
+	Duration   time.Duration `json:"duration_ns"`
+	RequestID  string        `json:"request_id,omitempty"`
+	UserID     string        `json:"user_id,omitempty"`
+	Referer    string        `json:"referer,omitempty"`
+	UserAgent  string        `json:"user_agent,omitempty"`
+}
+
+type accessLogKey struct{}
+
+// SetAccessLogUser records the authenticated user of the request in the
+// access log entry. Authentication middleware runs inside the route and
+// cannot change the request AccessLog sees, so it reports the user here.
+func SetAccessLogUser(ctx context.Context, userID string) {
+	if user, ok := ctx.Value(accessLogKey{}).(*atomic.Pointer[string]); ok {
+		user.Store(&userID)
+	}
+}
+
+// AccessLog returns middleware writing an access log line for every request
+// to cfg.Output.
+func AccessLog(cfg AccessLogConfig) func(http.Handler) http.Handler {
+	route := cfg.RouteFunc
+	if route == nil {
+		route = func(r *http.Request) string { return r.Pattern }
+	}
+	requestID := cfg.RequestIDFunc
+	if requestID == nil {
+		requestID = func(r *http.Request) string { return r.Header.Get("X-Request-Id") }
+	}
+	var mu sync.Mutex
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if matchPath(cfg.Exclude, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			entry := &AccessLogEntry{Time: time.Now()}
+			user := new(atomic.Pointer[string])
+			ww := WrapResponseWriter(w)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), accessLogKey{}, user)))
+
+			entry.Status = ww.Status()
+			if entry.Status == 0 {
+				entry.Status = http.StatusOK
+			}
+			if cfg.SampleRate > 0 && cfg.SampleRate < 1 &&
+				entry.Status < http.StatusInternalServerError && rand.Float64() >= cfg.SampleRate {
+				return
+			}
+			entry.Duration = time.Since(entry.Time)
+			entry.RemoteAddr = r.RemoteAddr
+			entry.Method = r.Method
+			entry.Path = r.URL.RequestURI()
+			entry.Proto = r.Proto
+			entry.Route = route(r)
+			entry.Bytes = ww.BytesWritten()
+			entry.RequestID = requestID(r)
+			if id := user.Load(); id != nil {
+				entry.UserID = *id
+			}
+			entry.Referer = r.Referer()
+			entry.UserAgent = r.UserAgent()
+
+			var buf bytes.Buffer
+			switch cfg.Format {
+			case FormatJSON:
+				_ = json.NewEncoder(&buf).Encode(entry)
+			case FormatLogfmt:
+				writeLogfmt(&buf, entry)
+			default:
+				writeCombined(&buf, entry)
+			}
+			mu.Lock()
+			_, _ = cfg.Output.Write(buf.Bytes())
+			mu.Unlock()
+		})
+	}
+}
+
+func writeCombined(buf *bytes.Buffer, e *AccessLogEntry) {
+	host := e.RemoteAddr
+	if i := strings.LastIndexByte(host, ':'); i > 0 {
+		host = host[:i]
+	}
+	bytesSent := "-"
+	if e.Bytes > 0 {
+		bytesSent = strconv.FormatInt(e.Bytes, 10)
+	}
+	fmt.Fprintf(buf, "%s - %s [%s] \"%s %s %s\" %d %s %q %q\n",
+		host,
+		dash(e.UserID),
+		e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		e.Method, e.Path, e.Proto,
+		e.Status,
+		bytesSent,
+		dash(e.Referer),
+		dash(e.UserAgent),
+	)
+}
+
+func writeLogfmt(buf *bytes.Buffer, e *AccessLogEntry) {
+	pairs := [][2]string{
+		{"time", e.Time.Format(time.RFC3339)},
+		{"remote_addr", e.RemoteAddr},
+		{"method", e.Method},
+		{"path", e.Path},
+		{"route", e.Route},
+		{"status", strconv.Itoa(e.Status)},
+		{"bytes", strconv.FormatInt(e.Bytes, 10)},
+		{"duration", e.Duration.String()},
+		{"request_id", e.RequestID},
+		{"user_id", e.UserID},
+		{"referer", e.Referer},
+		{"user_agent", e.UserAgent},
+	}
+	first := true
+	for _, kv := range pairs {
+		if kv[1] == "" {
+			continue
+		}
+		if !first {
+			buf.WriteByte(' ')
+		}
+		first = false
+		buf.WriteString(kv[0])
+		buf.WriteByte('=')
+		if strings.ContainsAny(kv[1], " \"=\\") {
+			buf.WriteString(strconv.Quote(kv[1]))
+		} else {
+			buf.WriteString(kv[1])
+		}
+	}
+	buf.WriteByte('\n')
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLog(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetAccessLogUser(r.Context(), "42")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	})
+	newReq := func(path string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, path, nil)
+		req.RemoteAddr = "1.2.3.4:5678"
+		req.Header.Set("X-Request-Id", "req-1")
+		req.Header.Set("User-Agent", "test agent")
+		return req
+	}
+
+	var buf bytes.Buffer
+	h := AccessLog(AccessLogConfig{Output: &buf, Exclude: []string{"/healthz", "/public/*"}})(handler)
+	h.ServeHTTP(httptest.NewRecorder(), newReq("/users?page=2"))
+	h.ServeHTTP(httptest.NewRecorder(), newReq("/healthz"))
+	h.ServeHTTP(httptest.NewRecorder(), newReq("/public/styles.css"))
+	line := buf.String()
+	if strings.Count(line, "\n") != 1 {
+		t.Fatalf("expected excluded paths not to be logged, got:\n%s", line)
+	}
+	if !strings.HasPrefix(line, "1.2.3.4 - 42 [") || !strings.Contains(line, `] "POST /users?page=2 HTTP/1.1" 201 5 "-" "test agent"`) {
+		t.Errorf("unexpected combined line %q", line)
+	}
+
+	buf.Reset()
+	h = AccessLog(AccessLogConfig{Output: &buf, Format: FormatLogfmt})(handler)
+	h.ServeHTTP(httptest.NewRecorder(), newReq("/users"))
+	for _, want := range []string{"method=POST", "status=201", "bytes=5", "request_id=req-1", "user_id=42", `user_agent="test agent"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected logfmt line to contain %s, got %q", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	h = AccessLog(AccessLogConfig{Output: &buf, Format: FormatJSON})(handler)
+	h.ServeHTTP(httptest.NewRecorder(), newReq("/users"))
+	var entry AccessLogEntry
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Status != http.StatusCreated || entry.Bytes != 5 || entry.UserID != "42" || entry.RequestID != "req-1" {
+		t.Errorf("unexpected JSON entry %+v", entry)
+	}
+}
@@ -0,0 +1,360 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header carrying the idempotency key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotencyFormField is the default form field carrying the
+	// idempotency key for plain HTML forms that cannot set headers.
+	IdempotencyFormField = "_idempotency_key"
+	// idempotencyReplayedHeader marks responses that were replayed.
+	idempotencyReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLen is the maximum accepted key length.
+	maxIdempotencyKeyLen = 255
+)
+
+// IdempotencyRecord is what an IdempotencyStore keeps per key.
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request the key was first used with.
+	Fingerprint string
+	// Completed is false while the first request is still being handled.
+	Completed bool
+	Status    int
+	Header    http.Header
+	Body      []byte
+}
+
+// IdempotencyStore stores responses of idempotent requests. Implementations
+// must be safe for concurrent use, and Lock must be atomic so that only one
+// request wins a key.
+type IdempotencyStore interface {
+	// Lock reserves key for a request with the given fingerprint. If key is
+	// unknown it is stored as an in-progress record and locked is true.
+	// Otherwise the existing record is returned and locked is false.
+	Lock(ctx context.Context, key, fingerprint string, ttl time.Duration) (rec IdempotencyRecord, locked bool, err error)
+	// Save stores the completed response for key.
+	Save(ctx context.Context, key string, rec IdempotencyRecord, ttl time.Duration) error
+	// Unlock removes key so the request can be retried.
+	Unlock(ctx context.Context, key string) error
+}
+
+// IdempotencyConfig configures WithIdempotency.
+type IdempotencyConfig struct {
+	// Store keeps the responses. Defaults to a MemoryIdempotencyStore.
+	Store IdempotencyStore
+	// TTL is how long responses are kept. Defaults to 24 hours.
+	TTL time.Duration
+	// LockTTL is how long a request in progress holds its key, so the key
+	// is freed if the instance handling it dies. It should exceed the
+	// longest handler run. Defaults to one minute.
+	LockTTL time.Duration
+	// Scope returns who the request is made by, for example the user ID.
+	// Keys are stored per scope, so a caller cannot have the response of
+	// another caller replayed by guessing their key. Defaults to one scope
+	// for everyone.
+	Scope func(r *http.Request) string
+	// Wait is how long a retry arriving while the first request is still in
+	// progress blocks before giving up with 409 Conflict. Zero responds with
+	// 409 straight away.
+	Wait time.Duration
+	// FormField is the form field read when the header is missing. Defaults
+	// to IdempotencyFormField.
+	FormField string
+	// MaxBodySize is the largest response body that is stored. Larger
+	// responses are passed through but not stored. Defaults to 1 MiB.
+	MaxBodySize int
+}
+
+// WithIdempotency makes POST, PUT and PATCH requests carrying an
+// Idempotency-Key header (or form field) safe to retry. The first response
+// for a key is stored and replayed for later requests with the same key.
+// Retries arriving while the first request runs wait up to cfg.Wait and then
+// get 409 Conflict, and reusing a key for a different request body gets 422
+// Unprocessable Entity. Server errors (5xx) are not stored so the request can
+// be retried, and Set-Cookie headers are never stored so replays cannot hand
+// out the session of the first request.
+func WithIdempotency(cfg IdempotencyConfig) func(http.Handler) http.Handler {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryIdempotencyStore()
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 24 * time.Hour
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = time.Minute
+	}
+	if cfg.FormField == "" {
+		cfg.FormField = IdempotencyFormField
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch:
+			default:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := r.Header.Get(IdempotencyKeyHeader)
+			mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if key == "" && mediaType != "application/x-www-form-urlencoded" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			body, err := readBody(r)
+			if err != nil {
+				var maxErr *http.MaxBytesError
+				if errors.As(err, &maxErr) {
+					http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			if key == "" {
+				if values, err := url.ParseQuery(string(body)); err == nil {
+					key = values.Get(cfg.FormField)
+				}
+			}
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLen {
+				http.Error(w, "idempotency key too long", http.StatusBadRequest)
+				return
+			}
+
+			if cfg.Scope != nil {
+				key = cfg.Scope(r) + "\x00" + key
+			}
+
+			ctx := r.Context()
+			fingerprint := requestFingerprint(r, body)
+			rec, locked, err := lockOrWait(ctx, cfg, key, fingerprint)
+			if err != nil {
+				slog.Error("idempotency store failed", "err", err)
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if !locked {
+				switch {
+				case rec.Fingerprint != fingerprint:
+					http.Error(w, "idempotency key reused with a different request", http.StatusUnprocessableEntity)
+				case !rec.Completed:
+					http.Error(w, "a request with this idempotency key is in progress", http.StatusConflict)
+				default:
+					replay(w, rec)
+				}
+				return
+			}
+
+			rw := &recordingWriter{ResponseWriter: w, header: w.Header(), max: cfg.MaxBodySize}
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				// The handler panicked or the response could not be stored;
+				// free the key so the client can retry.
+				if err := cfg.Store.Unlock(context.WithoutCancel(ctx), key); err != nil {
+					slog.Error("idempotency unlock failed", "err", err)
+				}
+			}()
+			next.ServeHTTP(rw, r)
+
+			status := rw.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= http.StatusInternalServerError || rw.overflow {
+				return
+			}
+			header := rw.header.Clone()
+			header.Del("Set-Cookie")
+			err = cfg.Store.Save(context.WithoutCancel(ctx), key, IdempotencyRecord{
+				Fingerprint: fingerprint,
+				Completed:   true,
+				Status:      status,
+				Header:      header,
+				Body:        rw.buf.Bytes(),
+			}, cfg.TTL)
+			if err != nil {
+				slog.Error("idempotency save failed", "err", err)
+				return
+			}
+			completed = true
+		})
+	}
+}
+
+// lockOrWait locks key or, if another request holds it, polls until that
+// request completes or cfg.Wait has passed.
+func lockOrWait(ctx context.Context, cfg IdempotencyConfig, key, fingerprint string) (IdempotencyRecord, bool, error) {
+	deadline := time.Now().Add(cfg.Wait)
+	for {
+		rec, locked, err := cfg.Store.Lock(ctx, key, fingerprint, cfg.LockTTL)
+		if err != nil || locked || rec.Completed || rec.Fingerprint != fingerprint {
+			return rec, locked, err
+		}
+		if !time.Now().Before(deadline) {
+			return rec, false, nil
+		}
+		select {
+		case <-ctx.Done():
+			return rec, false, nil
+		case <-time.After(25 * time.Millisecond):
+		}
+	}
+}
+
+func replay(w http.ResponseWriter, rec IdempotencyRecord) {
+	dst := w.Header()
+	for k, vals := range rec.Header {
+		dst[k] = vals
+	}
+	dst.Set(idempotencyReplayedHeader, "true")
+	w.WriteHeader(rec.Status)
+	_, _ = w.Write(rec.Body)
+}
+
+// readBody reads the request body and replaces it so the handler can read it
+// again.
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method)
+	io.WriteString(h, " ")
+	io.WriteString(h, r.URL.RequestURI())
+	io.WriteString(h, "\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter passes the response through to the client while keeping a
+// copy of it for the store.
+type recordingWriter struct {
+	http.ResponseWriter
+	header   http.Header
+	status   int
+	buf      bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (w *recordingWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.overflow {
+		if w.buf.Len()+len(b) > w.max {
+			w.overflow = true
+			w.buf.Reset()
+		} else {
+			w.buf.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying writer so http.ResponseController can reach it.
+func (w *recordingWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore. It is suitable for
+// single instance deployments and tests.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]memoryIdempotencyEntry
+	lastEvict time.Time
+}
+
+type memoryIdempotencyEntry struct {
+	rec       IdempotencyRecord
+	expiresAt time.Time
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]memoryIdempotencyEntry)}
+}
+
+// Lock implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Lock(_ context.Context, key, fingerprint string, ttl time.Duration) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if entry, ok := s.records[key]; ok && now.Before(entry.expiresAt) {
+		return entry.rec, false, nil
+	}
+	s.evictLocked(now)
+	s.records[key] = memoryIdempotencyEntry{
+		rec:       IdempotencyRecord{Fingerprint: fingerprint},
+		expiresAt: now.Add(ttl),
+	}
+	return IdempotencyRecord{}, true, nil
+}
+
+// Save implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Save(_ context.Context, key string, rec IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = memoryIdempotencyEntry{rec: rec, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// Unlock implements IdempotencyStore.
+func (s *MemoryIdempotencyStore) Unlock(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// evictLocked removes expired records at most once a minute. Callers hold s.mu.
+func (s *MemoryIdempotencyStore) evictLocked(now time.Time) {
+	if now.Sub(s.lastEvict) < time.Minute {
+		return
+	}
+	s.lastEvict = now
+	for key, entry := range s.records {
+		if !now.Before(entry.expiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithIdempotencyReplay(t *testing.T) {
+	var calls atomic.Int32
+	h := WithIdempotency(IdempotencyConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("X-Call", string(rune('0'+n)))
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("created"))
+	}))
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, "key-1")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	first := send(`{"amount":1}`)
+	second := send(`{"amount":1}`)
+	if calls.Load() != 1 {
+		t.Fatalf("expected handler to run once, ran %d times", calls.Load())
+	}
+	if second.Code != http.StatusCreated || second.Body.String() != "created" || second.Header().Get("X-Call") != first.Header().Get("X-Call") {
+		t.Errorf("expected stored response to be replayed, got %d %q", second.Code, second.Body.String())
+	}
+	if second.Header().Get(idempotencyReplayedHeader) != "true" {
+		t.Errorf("expected replayed header")
+	}
+
+	if rec := send(`{"amount":2}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for mismatched body got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+}
+
+func TestWithIdempotencyFormField(t *testing.T) {
+	var calls atomic.Int32
+	h := WithIdempotency(IdempotencyConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.FormValue("email") != "foo@bar.com" {
+			t.Errorf("expected handler to read the form")
+		}
+	}))
+	form := url.Values{"email": {"foo@bar.com"}, IdempotencyFormField: {"form-key"}}
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected handler to run once, ran %d times", calls.Load())
+	}
+}
+
+func TestWithIdempotencyConcurrent(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := WithIdempotency(IdempotencyConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	newReq := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("x"))
+		req.Header.Set(IdempotencyKeyHeader, "key")
+		return req
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.ServeHTTP(httptest.NewRecorder(), newReq())
+	}()
+	<-started
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, newReq())
+	if rec.Code != http.StatusConflict {
+		t.Errorf("expected %d got %d", http.StatusConflict, rec.Code)
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestWithIdempotencyWait(t *testing.T) {
+	h := WithIdempotency(IdempotencyConfig{Wait: time.Second})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("done"))
+	}))
+	var wg sync.WaitGroup
+	bodies := make([]string, 2)
+	for i := range bodies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("x"))
+			req.Header.Set(IdempotencyKeyHeader, "key")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			bodies[i] = rec.Body.String()
+		}()
+	}
+	wg.Wait()
+	if bodies[0] != "done" || bodies[1] != "done" {
+		t.Errorf("expected both requests to get the response, got %q", bodies)
+	}
+}
+
+func TestWithIdempotencyCookiesAndScope(t *testing.T) {
+	h := WithIdempotency(IdempotencyConfig{
+		Scope: func(r *http.Request) string { return r.Header.Get("X-User") },
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: r.Header.Get("X-User")})
+		w.Write([]byte("hello " + r.Header.Get("X-User")))
+	}))
+	send := func(user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("x"))
+		req.Header.Set(IdempotencyKeyHeader, "key")
+		req.Header.Set("X-User", user)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	send("alice")
+	replayed := send("alice")
+	if replayed.Header().Get(idempotencyReplayedHeader) != "true" {
+		t.Fatal("expected the response to be replayed")
+	}
+	if c := replayed.Header().Get("Set-Cookie"); c != "" {
+		t.Errorf("expected no cookie in the replay, got %q", c)
+	}
+	if rec := send("bob"); rec.Body.String() != "hello bob" {
+		t.Errorf("expected the key to be scoped to the caller, got %q", rec.Body.String())
+	}
+}
+
+func TestMemoryIdempotencyStoreLockTTL(t *testing.T) {
+	s := NewMemoryIdempotencyStore()
+	ctx := context.Background()
+	if _, locked, _ := s.Lock(ctx, "key", "fp", 10*time.Millisecond); !locked {
+		t.Fatal("expected the key to be locked")
+	}
+	if _, locked, _ := s.Lock(ctx, "key", "fp", time.Minute); locked {
+		t.Fatal("expected the key to be held")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, locked, _ := s.Lock(ctx, "key", "fp", time.Minute); !locked {
+		t.Fatal("expected the abandoned lock to expire")
+	}
+}
@@ -0,0 +1,186 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/a-h/templ"
+)
+
+// TimeoutConfig configures the WithTimeout middleware.
+type TimeoutConfig struct {
+	// Timeout is the deadline set on the request context. Defaults to 30
+	// seconds.
+	Timeout time.Duration
+	// StatusCode is written when the deadline passes. Defaults to
+	// http.StatusServiceUnavailable. Use http.StatusGatewayTimeout for routes
+	// that mostly wait on an upstream.
+	StatusCode int
+	// ErrorPage is rendered when the deadline passes. If nil a plain text
+	// status message is written instead.
+	ErrorPage templ.Component
+}
+
+// WithTimeout sets a deadline on the request context of every request passing
+// through it. The handler runs against a buffered ResponseWriter; if it does not
+// finish before the deadline its output is discarded and the configured error
+// page is rendered with the configured status code.
+//
+// Because the response is buffered, WithTimeout should not wrap streaming
+// routes such as server-sent events.
+func WithTimeout(cfg TimeoutConfig) func(http.Handler) http.Handler {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	status := cfg.StatusCode
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parent := r.Context()
+			ctx, cancel := context.WithTimeout(parent, cfg.Timeout)
+			defer cancel()
+
+			tw := &timeoutWriter{header: make(http.Header)}
+			done := make(chan struct{})
+			panicked := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						// http.ErrAbortHandler is handled by net/http itself
+						if p == http.ErrAbortHandler {
+							panicked <- p
+							return
+						}
+						panicked <- &HandlerPanic{Value: p, Stack: debug.Stack()}
+					}
+				}()
+				next.ServeHTTP(tw, r.WithContext(ctx))
+				close(done)
+			}()
+
+			select {
+			case p := <-panicked:
+				// Re-raise in the serving goroutine so recovery middleware
+				// sees it; the value carries the handler's stack.
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				dst := w.Header()
+				for k, vals := range tw.header {
+					dst[k] = vals
+				}
+				if !tw.wroteHeader {
+					tw.code = http.StatusOK
+				}
+				w.WriteHeader(tw.code)
+				_, _ = w.Write(tw.buf.Bytes())
+			case <-ctx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				// The client went away; there is nobody to write a response to.
+				if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+					return
+				}
+				writeErrorPage(w, parent, status, cfg.ErrorPage)
+			}
+		})
+	}
+}
+
+// HandlerPanic is the value WithTimeout re-panics with when the handler
+// panicked. The handler runs in its own goroutine, so the stack of the
+// re-panic does not show where the panic happened; Stack does.
+type HandlerPanic struct {
+	Value any
+	Stack []byte
+}
+
+func (p *HandlerPanic) Error() string {
+	return fmt.Sprintf("%v\n\n%s", p.Value, p.Stack)
+}
+
+// Unwrap returns the panic value if it is an error.
+func (p *HandlerPanic) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// WithMaxBodySize limits request bodies to n bytes. Requests announcing a
+// larger Content-Length are rejected with 413 straight away; other bodies are
+// wrapped in http.MaxBytesReader so reading past the limit fails with an
+// *http.MaxBytesError, which kit.BindJSON and validate.Request report as a 413.
+func WithMaxBodySize(n int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > n {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, n)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeErrorPage renders page with the given status or falls back to the
+// status text when no page is configured.
+func writeErrorPage(w http.ResponseWriter, ctx context.Context, status int, page templ.Component) {
+	if page == nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_ = page.Render(ctx, w)
+}
+
+// timeoutWriter buffers a handler's response so it can be discarded when the
+// deadline passes before the handler returns.
+type timeoutWriter struct {
+	mu          sync.Mutex
+	header      http.Header
+	buf         bytes.Buffer
+	code        int
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header { return tw.header }
+
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeaderLocked(http.StatusOK)
+	}
+	return tw.buf.Write(p)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeaderLocked(code)
+}
+
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+	tw.wroteHeader = true
+	tw.code = code
+}
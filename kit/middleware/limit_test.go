@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithTimeout(t *testing.T) {
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		w.Write([]byte("too late"))
+	})
+	h := WithTimeout(TimeoutConfig{Timeout: 10 * time.Millisecond, StatusCode: http.StatusGatewayTimeout})(slow)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected status %d got %d", http.StatusGatewayTimeout, rec.Code)
+	}
+	if strings.Contains(rec.Body.String(), "too late") {
+		t.Errorf("expected handler output to be discarded, got %q", rec.Body.String())
+	}
+}
+
+func TestWithTimeoutFast(t *testing.T) {
+	fast := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Foo", "bar")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("ok"))
+	})
+	// a zero Timeout uses the default rather than expiring at once
+	for _, timeout := range []time.Duration{time.Second, 0} {
+		h := WithTimeout(TimeoutConfig{Timeout: timeout})(fast)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("expected status %d got %d", http.StatusCreated, rec.Code)
+		}
+		if rec.Header().Get("X-Foo") != "bar" || rec.Body.String() != "ok" {
+			t.Errorf("unexpected response %v %q", rec.Header(), rec.Body.String())
+		}
+	}
+}
+
+func TestWithTimeoutPanic(t *testing.T) {
+	h := WithTimeout(TimeoutConfig{Timeout: time.Second})(http.HandlerFunc(panicHandler))
+
+	defer func() {
+		p, ok := recover().(*HandlerPanic)
+		if !ok {
+			t.Fatalf("expected a *HandlerPanic, got %v", p)
+		}
+		if p.Value != "boom" {
+			t.Errorf("expected panic value boom, got %v", p.Value)
+		}
+		if !strings.Contains(string(p.Stack), "panicHandler") {
+			t.Errorf("expected the stack to show the handler, got\n%s", p.Stack)
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
+
+func panicHandler(w http.ResponseWriter, r *http.Request) {
+	panic("boom")
+}
+
+func TestWithMaxBodySize(t *testing.T) {
+	var readErr error
+	h := WithMaxBodySize(4)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("too long")))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status %d got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+
+	// Without a Content-Length the limit is enforced while reading.
+	req := httptest.NewRequest(http.MethodPost, "/", io.NopCloser(strings.NewReader("too long")))
+	req.ContentLength = -1
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	var maxErr *http.MaxBytesError
+	if !errors.As(readErr, &maxErr) {
+		t.Errorf("expected *http.MaxBytesError got %v", readErr)
+	}
+}
@@ -0,0 +1,260 @@
+package middleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a-h/templ"
+)
+
+// MaintenanceState describes an active maintenance window.
+type MaintenanceState struct {
+	// Enabled reports whether the application is in maintenance mode.
+	Enabled bool `json:"-"`
+	// Since is when maintenance mode was enabled.
+	Since time.Time `json:"since"`
+	// RetryAfter is the number of seconds sent to clients in the Retry-After
+	// header.
+	RetryAfter int `json:"retry_after,omitempty"`
+	// Secret lets operators bypass maintenance mode by visiting /<secret>,
+	// which sets a bypass cookie and redirects to /.
+	Secret string `json:"secret,omitempty"`
+	// AllowIPs are IP addresses or CIDR ranges that bypass maintenance mode.
+	AllowIPs []string `json:"allow_ips,omitempty"`
+}
+
+// MaintenanceSwitch reports whether maintenance mode is on. It is consulted
+// on requests, so toggling it takes effect without a restart.
+type MaintenanceSwitch interface {
+	State(ctx context.Context) (MaintenanceState, error)
+}
+
+// MaintenanceFile is a MaintenanceSwitch backed by a flag file. Maintenance
+// mode is on while the file exists; its JSON content holds the
+// MaintenanceState.
+type MaintenanceFile string
+
+// State implements MaintenanceSwitch.
+func (f MaintenanceFile) State(context.Context) (MaintenanceState, error) {
+	var state MaintenanceState
+	b, err := os.ReadFile(string(f))
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &state); err != nil {
+			return state, err
+		}
+	}
+	state.Enabled = true
+	return state, nil
+}
+
+// Enable turns maintenance mode on by writing the flag file.
+func (f MaintenanceFile) Enable(state MaintenanceState) error {
+	if state.Since.IsZero() {
+		state.Since = time.Now().UTC()
+	}
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(string(f)); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	// Write to a temporary file first so requests never read a partial state.
+	tmp := string(f) + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, string(f))
+}
+
+// Disable turns maintenance mode off by removing the flag file.
+func (f MaintenanceFile) Disable() error {
+	err := os.Remove(string(f))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// MaintenanceConfig configures WithMaintenance.
+type MaintenanceConfig struct {
+	// Switch reports the maintenance state.
+	Switch MaintenanceSwitch
+	// Page is rendered while in maintenance mode. If nil a plain text message
+	// is written instead.
+	Page templ.Component
+	// AllowIPs are IP addresses or CIDR ranges that always bypass maintenance
+	// mode, in addition to those in the state.
+	// They are matched against r.RemoteAddr, see WithRealIP.
+	AllowIPs []string
+	// BypassPaths are served normally during maintenance. Entries ending in
+	// "*" match by prefix. Defaults to /healthz.
+	BypassPaths []string
+	// CookieName is the name of the bypass cookie. Defaults to
+	// "superkit_maintenance".
+	CookieName string
+	// RetryAfter is used when the state does not specify one. Defaults to
+	// 60 seconds.
+	RetryAfter time.Duration
+	// CacheTTL is how long the state is cached between lookups. Defaults to
+	// one second.
+	CacheTTL time.Duration
+}
+
+// WithMaintenance responds with 503 Service Unavailable, a Retry-After header
+// and the configured page while the switch reports maintenance mode.
+// Allowlisted IPs, requests carrying the bypass cookie and the bypass paths
+// are served normally.
+func WithMaintenance(cfg MaintenanceConfig) func(http.Handler) http.Handler {
+	if cfg.BypassPaths == nil {
+		cfg.BypassPaths = []string{"/healthz"}
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "superkit_maintenance"
+	}
+	if cfg.RetryAfter <= 0 {
+		cfg.RetryAfter = 60 * time.Second
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = time.Second
+	}
+	cache := &maintenanceCache{sw: cfg.Switch, ttl: cfg.CacheTTL}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			state := cache.state(r.Context())
+			if !state.Enabled || matchPath(cfg.BypassPaths, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if state.Secret != "" {
+				token := bypassToken(state.Secret)
+				if r.URL.Path == "/"+state.Secret {
+					http.SetCookie(w, &http.Cookie{
+						Name:     cfg.CookieName,
+						Value:    token,
+						Path:     "/",
+						HttpOnly: true,
+						Secure:   r.TLS != nil,
+						SameSite: http.SameSiteLaxMode,
+					})
+					http.Redirect(w, r, "/", http.StatusSeeOther)
+					return
+				}
+				if c, err := r.Cookie(cfg.CookieName); err == nil &&
+					subtle.ConstantTimeCompare([]byte(c.Value), []byte(token)) == 1 {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			ip := clientIP(r)
+			if ipAllowed(ip, cfg.AllowIPs) || ipAllowed(ip, state.AllowIPs) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			retryAfter := state.RetryAfter
+			if retryAfter <= 0 {
+				retryAfter = int(cfg.RetryAfter.Seconds())
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			w.Header().Set("Cache-Control", "no-store")
+			writeErrorPage(w, r.Context(), http.StatusServiceUnavailable, cfg.Page)
+		})
+	}
+}
+
+// maintenanceCache caches the switch state so the flag file is not read on
+// every request.
+type maintenanceCache struct {
+	sw  MaintenanceSwitch
+	ttl time.Duration
+
+	mu      sync.Mutex
+	current MaintenanceState
+	expires time.Time
+}
+
+func (c *maintenanceCache) state(ctx context.Context) MaintenanceState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Now().Before(c.expires) {
+		return c.current
+	}
+	state, err := c.sw.State(ctx)
+	if err != nil {
+		// Keep serving with the last known state rather than failing requests.
+		slog.Error("reading maintenance state failed", "err", err)
+		state = c.current
+	}
+	c.current = state
+	c.expires = time.Now().Add(c.ttl)
+	return state
+}
+
+func bypassToken(secret string) string {
+	sum := sha256.Sum256([]byte("superkit-maintenance:" + secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func matchPath(patterns []string, path string) bool {
+	return slices.ContainsFunc(patterns, func(p string) bool {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			return strings.HasPrefix(path, prefix)
+		}
+		return p == path
+	})
+}
+
+// clientIP returns the IP of r.RemoteAddr. Behind a reverse proxy it must
+// have been rewritten by WithRealIP, which only believes trusted proxies;
+// middleware taking forwarded headers from anyone lets clients claim an
+// allowlisted IP.
+func clientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+func ipAllowed(ip net.IP, allow []string) bool {
+	if ip == nil {
+		return false
+	}
+	for _, entry := range allow {
+		if strings.Contains(entry, "/") {
+			if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if allowed := net.ParseIP(entry); allowed != nil && allowed.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithMaintenance(t *testing.T) {
+	flag := MaintenanceFile(filepath.Join(t.TempDir(), "maintenance.json"))
+	h := WithMaintenance(MaintenanceConfig{
+		Switch:   flag,
+		CacheTTL: time.Nanosecond,
+		AllowIPs: []string{"10.0.0.0/8"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	get := func(path, remote string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remote
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get("/", "1.2.3.4:1234"); rec.Code != http.StatusOK {
+		t.Fatalf("expected %d before maintenance got %d", http.StatusOK, rec.Code)
+	}
+
+	if err := flag.Enable(MaintenanceState{Secret: "letmein", RetryAfter: 120}); err != nil {
+		t.Fatal(err)
+	}
+	rec := get("/", "1.2.3.4:1234")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "120" {
+		t.Errorf("expected 503 with Retry-After 120, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := get("/healthz", "1.2.3.4:1234"); rec.Code != http.StatusOK {
+		t.Errorf("expected /healthz to bypass maintenance, got %d", rec.Code)
+	}
+	if rec := get("/", "10.1.2.3:1234"); rec.Code != http.StatusOK {
+		t.Errorf("expected allowlisted IP to bypass maintenance, got %d", rec.Code)
+	}
+
+	rec = get("/letmein", "1.2.3.4:1234")
+	if rec.Code != http.StatusSeeOther || len(rec.Result().Cookies()) != 1 {
+		t.Fatalf("expected secret path to set a bypass cookie, got %d", rec.Code)
+	}
+	if rec := get("/", "1.2.3.4:1234", rec.Result().Cookies()[0]); rec.Code != http.StatusOK {
+		t.Errorf("expected bypass cookie to bypass maintenance, got %d", rec.Code)
+	}
+
+	if err := flag.Disable(); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get("/", "1.2.3.4:1234"); rec.Code != http.StatusOK {
+		t.Errorf("expected %d after maintenance got %d", http.StatusOK, rec.Code)
+	}
+}
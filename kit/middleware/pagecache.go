@@ -0,0 +1,400 @@
+package middleware
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/khulnasoft/superkit/db"
+	"github.com/khulnasoft/superkit/kit"
+)
+
+// CachedPage is a stored response.
+type CachedPage struct {
+	Status    int
+	Header    http.Header
+	Body      []byte
+	Tags      []string
+	ExpiresAt time.Time
+}
+
+// PageCacheStore stores rendered pages. Implementations must be safe for
+// concurrent use.
+type PageCacheStore interface {
+	// Get returns the page stored under key. Expired pages are not returned.
+	Get(ctx context.Context, key string) (CachedPage, bool, error)
+	// Set stores page under key.
+	Set(ctx context.Context, key string, page CachedPage) error
+	// InvalidateTags removes every page tagged with any of tags.
+	InvalidateTags(ctx context.Context, tags ...string) error
+}
+
+// PageCacheConfig configures a PageCache.
+type PageCacheConfig struct {
+	// Store holds the pages. Defaults to a MemoryPageCacheStore holding
+	// 1000 pages.
+	Store PageCacheStore
+	// TTL is how long a page is served from the cache. Defaults to 5 minutes.
+	TTL time.Duration
+	// IgnoreQuery makes requests differing only in their query string share
+	// a cache entry.
+	IgnoreQuery bool
+	// VaryHeaders are request headers whose values are part of the key, for
+	// example Accept-Language.
+	VaryHeaders []string
+	// VaryAuth keeps separate entries for authenticated and anonymous
+	// visitors based on kit.Auth.Check. The middleware must then run after
+	// kit.WithAuthentication.
+	VaryAuth bool
+	// Tags returns tags attached to every page cached for r, in addition to
+	// those added by handlers through CacheTags.
+	Tags func(r *http.Request) []string
+	// MaxBodySize is the largest body that is cached. Defaults to 1 MiB.
+	MaxBodySize int
+}
+
+// PageCache caches rendered GET responses. Pages can be invalidated by tag,
+// for example from an event subscriber when the underlying content changes:
+//
+//	pages := middleware.NewPageCache(middleware.PageCacheConfig{VaryAuth: true})
+//	r.With(pages.Middleware).Get("/", kit.Handler(handlers.HandleLandingIndex))
+//	event.Subscribe("post.updated", func(ctx context.Context, _ any) {
+//		pages.Invalidate(ctx, "posts")
+//	})
+//
+// Only 200 responses are cached, and never when they set a cookie or a
+// Cache-Control header containing no-store or private. HTMX partial requests
+// (HX-Request header) bypass the cache.
+type PageCache struct {
+	cfg PageCacheConfig
+}
+
+// NewPageCache returns a PageCache configured by cfg.
+func NewPageCache(cfg PageCacheConfig) *PageCache {
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryPageCacheStore(1000)
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 5 * time.Minute
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+	return &PageCache{cfg: cfg}
+}
+
+// Invalidate removes every cached page tagged with any of tags.
+func (c *PageCache) Invalidate(ctx context.Context, tags ...string) error {
+	return c.cfg.Store.InvalidateTags(ctx, tags...)
+}
+
+type cacheTagsKey struct{}
+
+type cacheTags struct {
+	mu   sync.Mutex
+	tags []string
+}
+
+// CacheTags attaches tags to the page being rendered so it can later be
+// invalidated with PageCache.Invalidate. It is a no-op outside a PageCache.
+func CacheTags(ctx context.Context, tags ...string) {
+	if t, ok := ctx.Value(cacheTagsKey{}).(*cacheTags); ok {
+		t.mu.Lock()
+		t.tags = append(t.tags, tags...)
+		t.mu.Unlock()
+	}
+}
+
+// Middleware serves cached pages and caches rendered ones.
+func (c *PageCache) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.Header.Get("HX-Request") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		key := c.key(r)
+		page, ok, err := c.cfg.Store.Get(ctx, key)
+		if err != nil {
+			slog.Error("page cache lookup failed", "err", err)
+		}
+		if ok {
+			dst := w.Header()
+			for k, vals := range page.Header {
+				dst[k] = vals
+			}
+			dst.Set("X-Cache", "HIT")
+			w.WriteHeader(page.Status)
+			_, _ = w.Write(page.Body)
+			return
+		}
+
+		tags := &cacheTags{}
+		w.Header().Set("X-Cache", "MISS")
+		rw := &recordingWriter{ResponseWriter: w, header: w.Header(), max: c.cfg.MaxBodySize}
+		next.ServeHTTP(rw, r.WithContext(context.WithValue(ctx, cacheTagsKey{}, tags)))
+
+		if !cacheable(rw) {
+			return
+		}
+		header := rw.header.Clone()
+		header.Del("X-Cache")
+		page = CachedPage{
+			Status:    http.StatusOK,
+			Header:    header,
+			Body:      rw.buf.Bytes(),
+			ExpiresAt: time.Now().Add(c.cfg.TTL),
+		}
+		if c.cfg.Tags != nil {
+			page.Tags = append(page.Tags, c.cfg.Tags(r)...)
+		}
+		tags.mu.Lock()
+		page.Tags = append(page.Tags, tags.tags...)
+		tags.mu.Unlock()
+		slices.Sort(page.Tags)
+		page.Tags = slices.Compact(page.Tags)
+		if err := c.cfg.Store.Set(context.WithoutCancel(ctx), key, page); err != nil {
+			slog.Error("page cache store failed", "err", err)
+		}
+	})
+}
+
+func cacheable(rw *recordingWriter) bool {
+	if rw.overflow || (rw.status != 0 && rw.status != http.StatusOK) {
+		return false
+	}
+	if rw.header.Get("Set-Cookie") != "" {
+		return false
+	}
+	cc := strings.ToLower(rw.header.Get("Cache-Control"))
+	return !strings.Contains(cc, "no-store") && !strings.Contains(cc, "private")
+}
+
+func (c *PageCache) key(r *http.Request) string {
+	h := sha256.New()
+	io.WriteString(h, r.Host)
+	io.WriteString(h, r.URL.Path)
+	if !c.cfg.IgnoreQuery {
+		io.WriteString(h, "?")
+		io.WriteString(h, r.URL.Query().Encode())
+	}
+	for _, name := range c.cfg.VaryHeaders {
+		io.WriteString(h, "\n"+name+":")
+		io.WriteString(h, strings.Join(r.Header.Values(name), ","))
+	}
+	if c.cfg.VaryAuth {
+		auth, ok := r.Context().Value(kit.AuthKey{}).(kit.Auth)
+		if ok && auth.Check() {
+			io.WriteString(h, "\nauth:1")
+		} else {
+			io.WriteString(h, "\nauth:0")
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// MemoryPageCacheStore is an in-memory PageCacheStore evicting the least
+// recently used page once it holds its maximum number of pages.
+type MemoryPageCacheStore struct {
+	mu      sync.Mutex
+	max     int
+	lru     *list.List
+	entries map[string]*list.Element
+	tags    map[string]map[string]struct{}
+}
+
+type memoryPage struct {
+	key  string
+	page CachedPage
+}
+
+// NewMemoryPageCacheStore returns a MemoryPageCacheStore holding at most
+// maxEntries pages.
+func NewMemoryPageCacheStore(maxEntries int) *MemoryPageCacheStore {
+	return &MemoryPageCacheStore{
+		max:     maxEntries,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+		tags:    make(map[string]map[string]struct{}),
+	}
+}
+
+// Get implements PageCacheStore.
+func (s *MemoryPageCacheStore) Get(_ context.Context, key string) (CachedPage, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return CachedPage{}, false, nil
+	}
+	entry := el.Value.(*memoryPage)
+	if !time.Now().Before(entry.page.ExpiresAt) {
+		s.removeLocked(el)
+		return CachedPage{}, false, nil
+	}
+	s.lru.MoveToFront(el)
+	return entry.page, true, nil
+}
+
+// Set implements PageCacheStore.
+func (s *MemoryPageCacheStore) Set(_ context.Context, key string, page CachedPage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.entries[key]; ok {
+		s.removeLocked(el)
+	}
+	s.entries[key] = s.lru.PushFront(&memoryPage{key: key, page: page})
+	for _, tag := range page.Tags {
+		if s.tags[tag] == nil {
+			s.tags[tag] = make(map[string]struct{})
+		}
+		s.tags[tag][key] = struct{}{}
+	}
+	for s.max > 0 && s.lru.Len() > s.max {
+		s.removeLocked(s.lru.Back())
+	}
+	return nil
+}
+
+// InvalidateTags implements PageCacheStore.
+func (s *MemoryPageCacheStore) InvalidateTags(_ context.Context, tags ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, tag := range tags {
+		for key := range s.tags[tag] {
+			if el, ok := s.entries[key]; ok {
+				s.removeLocked(el)
+			}
+		}
+	}
+	return nil
+}
+
+// Len returns the number of stored pages.
+func (s *MemoryPageCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// removeLocked removes el and its tag index entries. Callers hold s.mu.
+func (s *MemoryPageCacheStore) removeLocked(el *list.Element) {
+	entry := s.lru.Remove(el).(*memoryPage)
+	delete(s.entries, entry.key)
+	for _, tag := range entry.page.Tags {
+		delete(s.tags[tag], entry.key)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+}
+
+// SQLPageCacheStore is a PageCacheStore backed by a SQL table, which lets
+// several instances share cached pages. The table is expected to look like:
+//
+//	CREATE TABLE page_cache (
+//		cache_key  TEXT PRIMARY KEY,
+//		status     INTEGER NOT NULL,
+//		header     TEXT NOT NULL,
+//		body       BLOB NOT NULL,
+//		tags       TEXT NOT NULL,
+//		expires_at TIMESTAMP NOT NULL
+//	);
+//
+// with BYTEA for body on postgres, and VARCHAR(255) for cache_key and
+// LONGBLOB for body on mysql.
+//
+// Tags are stored comma separated and wrapped in commas so a single tag can
+// be matched with LIKE. Tags must not contain commas.
+type SQLPageCacheStore struct {
+	db     *sql.DB
+	driver string
+	table  string
+}
+
+// NewSQLPageCacheStore returns a store using table in db. driver is the
+// database/sql driver name of db, such as "sqlite3", "mysql", "postgres" or
+// "pgx", which decides the placeholder style.
+func NewSQLPageCacheStore(db *sql.DB, driver, table string) *SQLPageCacheStore {
+	return &SQLPageCacheStore{db: db, driver: driver, table: table}
+}
+
+// Get implements PageCacheStore.
+func (s *SQLPageCacheStore) Get(ctx context.Context, key string) (CachedPage, bool, error) {
+	var (
+		page   CachedPage
+		header string
+		tags   string
+	)
+	err := s.db.QueryRowContext(ctx, s.rebind(
+		"SELECT status, header, body, tags, expires_at FROM "+s.table+" WHERE cache_key = ? AND expires_at > ?"),
+		key, time.Now().UTC(),
+	).Scan(&page.Status, &header, &page.Body, &tags, &page.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return page, false, nil
+	}
+	if err != nil {
+		return page, false, err
+	}
+	if err := json.Unmarshal([]byte(header), &page.Header); err != nil {
+		return page, false, err
+	}
+	page.Tags = strings.Split(strings.Trim(tags, ","), ",")
+	return page, true, nil
+}
+
+// Set implements PageCacheStore.
+func (s *SQLPageCacheStore) Set(ctx context.Context, key string, page CachedPage) error {
+	header, err := json.Marshal(page.Header)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, s.rebind("DELETE FROM "+s.table+" WHERE cache_key = ?"), key); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.rebind(
+		"INSERT INTO "+s.table+" (cache_key, status, header, body, tags, expires_at) VALUES (?, ?, ?, ?, ?, ?)"),
+		key, page.Status, string(header), page.Body, ","+strings.Join(page.Tags, ",")+",", page.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// InvalidateTags implements PageCacheStore. Expired pages are purged as well.
+func (s *SQLPageCacheStore) InvalidateTags(ctx context.Context, tags ...string) error {
+	query := "DELETE FROM " + s.table + " WHERE expires_at <= ?"
+	args := []any{time.Now().UTC()}
+	for _, tag := range tags {
+		query += " OR tags LIKE ? ESCAPE '!'"
+		args = append(args, "%,"+likeEscaper.Replace(tag)+",%")
+	}
+	_, err := s.db.ExecContext(ctx, s.rebind(query), args...)
+	return err
+}
+
+// likeEscaper escapes the LIKE wildcards with "!". Unlike a backslash, "!"
+// needs no quoting in SQL string literals on any database.
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// rebind rewrites the "?" placeholders of query for the store's driver.
+func (s *SQLPageCacheStore) rebind(query string) string {
+	return db.Rebind(s.driver, query)
+}
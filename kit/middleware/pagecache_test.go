@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/superkit/kit"
+)
+
+type testAuth bool
+
+func (a testAuth) Check() bool { return bool(a) }
+
+func TestPageCache(t *testing.T) {
+	var renders atomic.Int32
+	pages := NewPageCache(PageCacheConfig{VaryAuth: true})
+	h := pages.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renders.Add(1)
+		CacheTags(r.Context(), "landing")
+		w.Write([]byte("page"))
+	}))
+	get := func(loggedIn bool, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), kit.AuthKey{}, kit.Auth(testAuth(loggedIn))))
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := get(false); rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("expected first request to miss")
+	}
+	if rec := get(false); rec.Header().Get("X-Cache") != "HIT" || rec.Body.String() != "page" {
+		t.Errorf("expected second request to hit, got %q", rec.Body.String())
+	}
+	if renders.Load() != 1 {
+		t.Errorf("expected 1 render got %d", renders.Load())
+	}
+
+	get(true)
+	if renders.Load() != 2 {
+		t.Errorf("expected authenticated visitors to get their own entry")
+	}
+
+	get(false, "HX-Request", "true")
+	if renders.Load() != 3 {
+		t.Errorf("expected HTMX requests to bypass the cache")
+	}
+
+	if err := pages.Invalidate(context.Background(), "landing"); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get(false); rec.Header().Get("X-Cache") != "MISS" {
+		t.Errorf("expected invalidated page to miss")
+	}
+}
+
+func TestPageCacheSkipsUncacheable(t *testing.T) {
+	var renders atomic.Int32
+	h := NewPageCache(PageCacheConfig{}).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renders.Add(1)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+		w.Write([]byte("private"))
+	}))
+	for i := 0; i < 2; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if renders.Load() != 2 {
+		t.Errorf("expected responses setting cookies not to be cached")
+	}
+}
+
+func TestMemoryPageCacheStoreEviction(t *testing.T) {
+	store := NewMemoryPageCacheStore(2)
+	ctx := context.Background()
+	page := CachedPage{Status: http.StatusOK, ExpiresAt: time.Now().Add(time.Hour), Tags: []string{"t"}}
+	store.Set(ctx, "a", page)
+	store.Set(ctx, "b", page)
+	store.Get(ctx, "a")
+	store.Set(ctx, "c", page)
+	if _, ok, _ := store.Get(ctx, "b"); ok {
+		t.Errorf("expected least recently used entry to be evicted")
+	}
+	if _, ok, _ := store.Get(ctx, "a"); !ok {
+		t.Errorf("expected recently used entry to be kept")
+	}
+	store.InvalidateTags(ctx, "t")
+	if store.Len() != 0 {
+		t.Errorf("expected all tagged entries to be invalidated, %d left", store.Len())
+	}
+}
+
+func TestSQLPageCacheStoreQueries(t *testing.T) {
+	pg := NewSQLPageCacheStore(nil, "pgx", "page_cache")
+	if got := pg.rebind("a = ? AND b LIKE ? ESCAPE '!'"); got != "a = $1 AND b LIKE $2 ESCAPE '!'" {
+		t.Errorf("unexpected postgres query %q", got)
+	}
+	if got := NewSQLPageCacheStore(nil, "sqlite3", "page_cache").rebind("a = ?"); got != "a = ?" {
+		t.Errorf("unexpected sqlite query %q", got)
+	}
+	if got := likeEscaper.Replace("50%_off!"); got != "50!%!_off!!" {
+		t.Errorf("unexpected escaped tag %q", got)
+	}
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RealIPConfig configures WithRealIP.
+type RealIPConfig struct {
+	// TrustedProxies are the IP addresses or CIDR ranges of the reverse
+	// proxies in front of the application. Forwarded headers are only
+	// honored on requests coming from them, so without any the headers are
+	// ignored.
+	TrustedProxies []string
+}
+
+// WithRealIP sets r.RemoteAddr to the client IP reported by a trusted proxy
+// in the X-Forwarded-For or X-Real-IP header. Unlike middleware believing
+// these headers on every request, clients cannot pose as another IP, which
+// matters for IP allowlists such as MaintenanceConfig.AllowIPs.
+//
+// X-Forwarded-For is read from the right, skipping trusted proxies, so the
+// first untrusted address is the client that connected to the outermost
+// proxy.
+func WithRealIP(cfg RealIPConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ipAllowed(clientIP(r), cfg.TrustedProxies) {
+				if ip := forwardedIP(r, cfg.TrustedProxies); ip != nil {
+					r.RemoteAddr = ip.String()
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedIP returns the client IP from the forwarded headers of r, or nil.
+func forwardedIP(r *http.Request, trusted []string) net.IP {
+	var client net.IP
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			// a garbled entry: everything left of it may be forged
+			break
+		}
+		client = ip
+		if !ipAllowed(ip, trusted) {
+			break
+		}
+	}
+	if client != nil {
+		return client
+	}
+	return net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+}
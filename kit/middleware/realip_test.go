@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithRealIP(t *testing.T) {
+	h := WithRealIP(RealIPConfig{TrustedProxies: []string{"10.0.0.0/8"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.RemoteAddr))
+	}))
+
+	for _, tc := range []struct {
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{remote: "1.2.3.4:1234", want: "1.2.3.4:1234"},
+		// forwarded headers from untrusted peers are ignored
+		{remote: "1.2.3.4:1234", headers: map[string]string{"X-Forwarded-For": "10.1.1.1"}, want: "1.2.3.4:1234"},
+		{remote: "1.2.3.4:1234", headers: map[string]string{"X-Real-IP": "10.1.1.1"}, want: "1.2.3.4:1234"},
+		{remote: "10.0.0.1:1234", headers: map[string]string{"X-Real-IP": "5.6.7.8"}, want: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", headers: map[string]string{"X-Forwarded-For": "5.6.7.8"}, want: "5.6.7.8"},
+		// a client prepending an address cannot skip past its own
+		{remote: "10.0.0.1:1234", headers: map[string]string{"X-Forwarded-For": "10.9.9.9, 5.6.7.8, 10.0.0.2"}, want: "5.6.7.8"},
+		{remote: "10.0.0.1:1234", headers: map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, want: "10.0.0.3"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remote
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if got := rec.Body.String(); got != tc.want {
+			t.Errorf("remote %s headers %v: expected %s got %s", tc.remote, tc.headers, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package middleware
+
+import "net/http"
+
+// ResponseWriter wraps an http.ResponseWriter to record the status code and
+// the number of body bytes written by the handler.
+type ResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+// WrapResponseWriter returns a ResponseWriter recording what is written to w.
+// If w already is a *ResponseWriter it is returned as is.
+func WrapResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	if rw, ok := w.(*ResponseWriter); ok {
+		return rw
+	}
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+func (w *ResponseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying writer does.
+func (w *ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer so http.ResponseController can reach it.
+func (w *ResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// Status returns the status code written so far. Handlers that write a body
+// without calling WriteHeader get http.StatusOK; handlers that write nothing
+// report 0 until the server writes its implicit 200.
+func (w *ResponseWriter) Status() int {
+	if !w.wroteHeader {
+		return 0
+	}
+	return w.status
+}
+
+// BytesWritten returns the number of body bytes written.
+func (w *ResponseWriter) BytesWritten() int64 { return w.bytes }
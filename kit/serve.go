@@ -0,0 +1,123 @@
+package kit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ServeConfig configures Serve.
+type ServeConfig struct {
+	// Addr is the address to listen on. Defaults to HTTP_LISTEN_ADDR or,
+	// if unset, ":8080".
+	Addr string
+	// Handler serves the requests.
+	Handler http.Handler
+	// Background functions run alongside the server, for example job
+	// workers or the event outbox. Their context is cancelled on shutdown,
+	// once the server stopped accepting requests, and Serve waits for them
+	// to return. A function returning an error shuts the server down.
+	Background []func(ctx context.Context) error
+	// OnShutdown functions run last, after the server and the background
+	// functions stopped, for example to flush the tracer.
+	OnShutdown []func(ctx context.Context) error
+	// ShutdownTimeout bounds the whole graceful shutdown. Defaults to 30
+	// seconds.
+	ShutdownTimeout time.Duration
+	// Listener is used instead of listening on Addr when set.
+	Listener net.Listener
+}
+
+// Serve runs an HTTP server with the background functions of cfg until ctx
+// is cancelled or the process receives SIGINT or SIGTERM, then shuts down
+// gracefully: in-flight requests are completed, the background functions
+// are stopped and the shutdown hooks run, all within ShutdownTimeout.
+//
+//	err := kit.Serve(context.Background(), kit.ServeConfig{
+//		Handler:    router,
+//		Background: []func(context.Context) error{jobClient.Run},
+//		OnShutdown: []func(context.Context) error{tracer.Shutdown},
+//	})
+func Serve(ctx context.Context, cfg ServeConfig) error {
+	if cfg.Addr == "" {
+		cfg.Addr = Getenv("HTTP_LISTEN_ADDR", ":8080")
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = 30 * time.Second
+	}
+	ln := cfg.Listener
+	if ln == nil {
+		var err error
+		ln, err = net.Listen("tcp", cfg.Addr)
+		if err != nil {
+			return fmt.Errorf("kit: listen: %w", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := &http.Server{Handler: cfg.Handler}
+	errc := make(chan error, 1+len(cfg.Background))
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errc <- fmt.Errorf("kit: serve: %w", err)
+		}
+	}()
+
+	bgCtx, cancelBackground := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelBackground()
+	var wg sync.WaitGroup
+	for _, fn := range cfg.Background {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := fn(bgCtx); err != nil {
+				errc <- err
+			}
+		}()
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutdown signal received, shutting down")
+	case runErr = <-errc:
+		slog.Error("shutting down", "err", runErr)
+	}
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	errs := []error{runErr}
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("kit: shutdown server: %w", err))
+	}
+
+	cancelBackground()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		errs = append(errs, errors.New("kit: background functions did not stop in time"))
+	}
+
+	for _, fn := range cfg.OnShutdown {
+		if err := fn(shutdownCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,85 @@
+package kit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestServeGracefulShutdown(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		background atomic.Bool
+		hookRan    atomic.Bool
+	)
+	started := make(chan struct{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, ServeConfig{
+			Listener: ln,
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "ok")
+			}),
+			Background: []func(context.Context) error{func(ctx context.Context) error {
+				close(started)
+				<-ctx.Done()
+				background.Store(true)
+				return nil
+			}},
+			OnShutdown: []func(context.Context) error{func(context.Context) error {
+				if !background.Load() {
+					t.Error("expected background functions to stop before the shutdown hooks")
+				}
+				hookRan.Store(true)
+				return nil
+			}},
+		})
+	}()
+
+	<-started
+	res, err := http.Get("http://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return")
+	}
+	if !hookRan.Load() {
+		t.Fatal("expected shutdown hook to run")
+	}
+}
+
+func TestServeBackgroundError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	boom := errors.New("boom")
+	err = Serve(context.Background(), ServeConfig{
+		Listener: ln,
+		Handler:  http.NotFoundHandler(),
+		Background: []func(context.Context) error{func(context.Context) error {
+			return boom
+		}},
+	})
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected background error got %v", err)
+	}
+}
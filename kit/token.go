@@ -0,0 +1,18 @@
+package kit
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// ValidBearerToken reports whether r carries token as a bearer token in the
+// Authorization header. Tokens in the query string are not accepted as they
+// end up in access logs and proxy logs. An empty token never matches.
+func ValidBearerToken(r *http.Request, token string) bool {
+	auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth), []byte(token)) == 1
+}
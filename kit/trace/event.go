@@ -0,0 +1,40 @@
+package trace
+
+import (
+	"context"
+
+	"github.com/khulnasoft/superkit/event"
+)
+
+// Handlers of events emitted with event.EmitContext from inside a span
+// become part of its trace.
+func init() {
+	event.PropagateContext(spanKey{}, remoteKey{})
+}
+
+// WrapHandler wraps an event handler so every invocation runs in a consumer
+// span named after topic. If the handler context carries a span, the
+// invocation becomes part of its trace.
+//
+//	event.Subscribe(auth.UserSignupEvent, trace.WrapHandler(tracer, auth.UserSignupEvent, events.OnUserSignup))
+func WrapHandler(t *Tracer, topic string, h event.HandlerFunc) event.HandlerFunc {
+	return func(ctx context.Context, msg any) {
+		ctx, span := t.Start(ctx, topic, WithKind(KindConsumer), WithAttributes(map[string]any{
+			"messaging.destination.name": topic,
+		}))
+		defer span.End()
+		h(ctx, msg)
+	}
+}
+
+// EventMiddleware returns event middleware running every handler invocation
+// in a consumer span named after the topic of the event.
+//
+//	event.Use(trace.EventMiddleware(tracer))
+func EventMiddleware(t *Tracer) event.Middleware {
+	return func(next event.HandlerFunc) event.HandlerFunc {
+		return func(ctx context.Context, msg any) {
+			WrapHandler(t, event.TopicFromContext(ctx), next)(ctx, msg)
+		}
+	}
+}
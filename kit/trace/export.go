@@ -0,0 +1,62 @@
+package trace
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// Exporter receives batches of finished spans.
+type Exporter interface {
+	// Export sends the given spans to their destination.
+	Export(ctx context.Context, spans []SpanData) error
+	// Shutdown flushes and releases any resources held by the exporter.
+	Shutdown(ctx context.Context) error
+}
+
+// JSONLinesExporter writes every span as a single JSON object per line.
+type JSONLinesExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLinesExporter returns an exporter writing spans to w. If w is an
+// io.Closer it is closed on Shutdown.
+func NewJSONLinesExporter(w io.Writer) *JSONLinesExporter {
+	return &JSONLinesExporter{w: w}
+}
+
+// NewFileExporter returns an exporter appending spans to the file at path,
+// creating it if needed.
+func NewFileExporter(path string) (*JSONLinesExporter, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONLinesExporter(f), nil
+}
+
+// Export implements Exporter.
+func (e *JSONLinesExporter) Export(_ context.Context, spans []SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	enc := json.NewEncoder(e.w)
+	for _, span := range spans {
+		if err := enc.Encode(span); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown implements Exporter.
+func (e *JSONLinesExporter) Shutdown(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if c, ok := e.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package trace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/khulnasoft/superkit/kit/middleware"
+)
+
+const (
+	traceparentHeader = "traceparent"
+	tracestateHeader  = "tracestate"
+)
+
+// Extract returns a copy of ctx holding the span context found in the
+// traceparent and tracestate headers of h. If no valid traceparent is present
+// ctx is returned unchanged.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, err := ParseTraceparent(h.Get(traceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	sc.TraceState = ParseTraceState(h.Get(tracestateHeader))
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Inject writes the span context held by ctx into the traceparent and
+// tracestate headers of h, so outgoing requests continue the trace.
+func Inject(ctx context.Context, h http.Header) {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(traceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		h.Set(tracestateHeader, sc.TraceState)
+	}
+}
+
+// MiddlewareConfig configures the tracing middleware.
+type MiddlewareConfig struct {
+	// RouteFunc returns the route pattern the request was matched against. It
+	// is called after the handler ran and used to name the span. Defaults to
+	// the pattern set by http.ServeMux.
+	RouteFunc func(*http.Request) string
+}
+
+// Middleware returns HTTP middleware that continues the trace of the incoming
+// request, or starts a new one, and wraps the handler in a server span. The
+// span is available to handlers through SpanFromContext and the response
+// carries a traceparent header identifying it.
+func Middleware(t *Tracer, cfg MiddlewareConfig) func(http.Handler) http.Handler {
+	route := cfg.RouteFunc
+	if route == nil {
+		route = func(r *http.Request) string { return r.Pattern }
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := Extract(r.Context(), r.Header)
+			ctx, span := t.Start(ctx, r.Method, WithKind(KindServer), WithAttributes(map[string]any{
+				"http.request.method": r.Method,
+				"url.path":            r.URL.Path,
+				"client.address":      r.RemoteAddr,
+			}))
+			defer span.End()
+
+			w.Header().Set(traceparentHeader, span.SpanContext().Traceparent())
+			ww := middleware.WrapResponseWriter(w)
+			r = r.WithContext(ctx)
+			next.ServeHTTP(ww, r)
+
+			if pattern := route(r); pattern != "" {
+				span.SetName(fmt.Sprintf("%s %s", r.Method, pattern))
+				span.SetAttribute("http.route", pattern)
+			}
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttribute("http.response.status_code", status)
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(StatusError, http.StatusText(status))
+			}
+		})
+	}
+}
+
+// Transport wraps an http.RoundTripper so that outgoing requests are traced in
+// a client span and carry the traceparent header. A nil base uses
+// http.DefaultTransport.
+func Transport(t *Tracer, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		ctx, span := t.Start(r.Context(), r.Method, WithKind(KindClient), WithAttributes(map[string]any{
+			"http.request.method": r.Method,
+			"url.full":            r.URL.String(),
+		}))
+		defer span.End()
+
+		r = r.Clone(ctx)
+		Inject(ctx, r.Header)
+		resp, err := base.RoundTrip(r)
+		if err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+		span.SetAttribute("http.response.status_code", resp.StatusCode)
+		if resp.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(StatusError, resp.Status)
+		}
+		return resp, nil
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
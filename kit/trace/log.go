@@ -0,0 +1,41 @@
+package trace
+
+import (
+	"context"
+	"log/slog"
+)
+
+// LogHandler is a slog.Handler adding the trace_id and span_id of the span
+// in the record's context to every record, so that log lines of the same
+// request or event can be correlated.
+type LogHandler struct {
+	slog.Handler
+}
+
+// NewLogHandler wraps h in a LogHandler.
+//
+//	slog.SetDefault(slog.New(trace.NewLogHandler(slog.NewJSONHandler(os.Stdout, nil))))
+func NewLogHandler(h slog.Handler) *LogHandler {
+	return &LogHandler{Handler: h}
+}
+
+// Handle implements slog.Handler.
+func (h *LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID.String()),
+			slog.String("span_id", sc.SpanID.String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler.
+func (h *LogHandler) WithGroup(name string) slog.Handler {
+	return &LogHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,380 @@
+package trace
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SpanKind describes the relationship between a span and its parent.
+type SpanKind int
+
+const (
+	KindInternal SpanKind = iota + 1
+	KindServer
+	KindClient
+	KindProducer
+	KindConsumer
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case KindServer:
+		return "server"
+	case KindClient:
+		return "client"
+	case KindProducer:
+		return "producer"
+	case KindConsumer:
+		return "consumer"
+	default:
+		return "internal"
+	}
+}
+
+// StatusCode is the outcome of a span.
+type StatusCode int
+
+const (
+	StatusUnset StatusCode = iota
+	StatusOK
+	StatusError
+)
+
+// SpanData is the immutable snapshot of a finished span handed to exporters.
+type SpanData struct {
+	Name          string         `json:"name"`
+	Kind          SpanKind       `json:"kind"`
+	TraceID       string         `json:"traceId"`
+	SpanID        string         `json:"spanId"`
+	ParentSpanID  string         `json:"parentSpanId,omitempty"`
+	TraceState    string         `json:"traceState,omitempty"`
+	Service       string         `json:"service,omitempty"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	Status        StatusCode     `json:"status"`
+	StatusMessage string         `json:"statusMessage,omitempty"`
+}
+
+// Span is a single timed operation within a trace.
+type Span struct {
+	tracer *Tracer
+	sc     SpanContext
+	parent SpanID
+	kind   SpanKind
+	start  time.Time
+
+	mu            sync.Mutex
+	name          string
+	attrs         map[string]any
+	status        StatusCode
+	statusMessage string
+	ended         bool
+}
+
+// SpanContext returns the span's propagated context.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetName replaces the span's name. It is useful when the name is only known
+// after the operation ran, such as the matched route of an HTTP request.
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttribute sets an attribute on the span.
+func (s *Span) SetAttribute(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.attrs == nil {
+		s.attrs = make(map[string]any)
+	}
+	s.attrs[key] = value
+	s.mu.Unlock()
+}
+
+// SetStatus sets the span's status.
+func (s *Span) SetStatus(code StatusCode, msg string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.status = code
+	s.statusMessage = msg
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed with err. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.SetStatus(StatusError, err.Error())
+}
+
+// End finishes the span and hands it to the tracer's exporter. Calling End
+// more than once has no effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	end := time.Now()
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	data := SpanData{
+		Name:          s.name,
+		Kind:          s.kind,
+		TraceID:       s.sc.TraceID.String(),
+		SpanID:        s.sc.SpanID.String(),
+		TraceState:    s.sc.TraceState,
+		Start:         s.start,
+		End:           end,
+		Attributes:    maps.Clone(s.attrs),
+		Status:        s.status,
+		StatusMessage: s.statusMessage,
+	}
+	s.mu.Unlock()
+	if s.parent.IsValid() {
+		data.ParentSpanID = s.parent.String()
+	}
+	if s.tracer != nil && s.sc.IsSampled() {
+		s.tracer.enqueue(data)
+	}
+}
+
+// StartOption configures a span started by Tracer.Start.
+type StartOption func(*Span)
+
+// WithKind sets the kind of the span. Spans default to KindInternal.
+func WithKind(kind SpanKind) StartOption {
+	return func(s *Span) { s.kind = kind }
+}
+
+// WithAttributes sets initial attributes on the span.
+func WithAttributes(attrs map[string]any) StartOption {
+	return func(s *Span) {
+		for k, v := range attrs {
+			s.SetAttribute(k, v)
+		}
+	}
+}
+
+// Config configures a Tracer.
+type Config struct {
+	// ServiceName is attached to every exported span.
+	ServiceName string
+	// Exporter receives finished spans. If nil spans are created and
+	// propagated but never exported.
+	Exporter Exporter
+	// BatchSize is the number of spans exported at once. Defaults to 128.
+	BatchSize int
+	// FlushInterval is the maximum time a finished span waits before it is
+	// exported. Defaults to 5 seconds.
+	FlushInterval time.Duration
+	// QueueSize is the number of finished spans buffered for export. Spans
+	// finishing while the queue is full are dropped. Defaults to 2048.
+	QueueSize int
+}
+
+// Tracer creates spans and exports them in batches.
+type Tracer struct {
+	cfg     Config
+	queue   chan SpanData
+	flushch chan chan struct{}
+	done    chan struct{}
+	stopped atomic.Bool
+	once    sync.Once
+}
+
+// NewTracer returns a Tracer exporting through cfg.Exporter. Call Shutdown
+// to flush pending spans before the program exits.
+func NewTracer(cfg Config) *Tracer {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 128
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = 5 * time.Second
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 2048
+	}
+	t := &Tracer{
+		cfg:     cfg,
+		queue:   make(chan SpanData, cfg.QueueSize),
+		flushch: make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Start starts a span named name. If ctx holds a span or a remote span
+// context the new span becomes its child; otherwise a new trace is started.
+// The returned context holds the new span.
+func (t *Tracer) Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+	span := &Span{
+		tracer: t,
+		name:   name,
+		kind:   KindInternal,
+		start:  time.Now(),
+	}
+	if parent.IsValid() {
+		span.sc = SpanContext{
+			TraceID:    parent.TraceID,
+			SpanID:     newSpanID(),
+			Flags:      parent.Flags,
+			TraceState: parent.TraceState,
+		}
+		span.parent = parent.SpanID
+	} else {
+		span.sc = SpanContext{
+			TraceID: newTraceID(),
+			SpanID:  newSpanID(),
+			Flags:   FlagSampled,
+		}
+	}
+	for _, opt := range opts {
+		opt(span)
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// ForceFlush exports all spans finished so far.
+func (t *Tracer) ForceFlush(ctx context.Context) error {
+	if t.stopped.Load() || t.queue == nil {
+		return nil
+	}
+	ack := make(chan struct{})
+	select {
+	case t.flushch <- ack:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown flushes pending spans and shuts the exporter down. Spans ending
+// after Shutdown are discarded.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	var err error
+	t.once.Do(func() {
+		err = t.ForceFlush(ctx)
+		t.stopped.Store(true)
+		if t.done != nil {
+			close(t.done)
+		}
+		if t.cfg.Exporter != nil {
+			if serr := t.cfg.Exporter.Shutdown(ctx); err == nil {
+				err = serr
+			}
+		}
+	})
+	return err
+}
+
+func (t *Tracer) enqueue(data SpanData) {
+	if t.stopped.Load() || t.cfg.Exporter == nil {
+		return
+	}
+	data.Service = t.cfg.ServiceName
+	select {
+	case t.queue <- data:
+	default:
+		slog.Warn("trace queue full; dropping span", "span", data.Name)
+	}
+}
+
+func (t *Tracer) run() {
+	ticker := time.NewTicker(t.cfg.FlushInterval)
+	defer ticker.Stop()
+	batch := make([]SpanData, 0, t.cfg.BatchSize)
+
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := t.cfg.Exporter.Export(ctx, batch); err != nil {
+			slog.Error("trace export failed", "err", err, "spans", len(batch))
+		}
+		cancel()
+		batch = make([]SpanData, 0, t.cfg.BatchSize)
+	}
+	drain := func() {
+		for {
+			select {
+			case data := <-t.queue:
+				batch = append(batch, data)
+				if len(batch) >= t.cfg.BatchSize {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case <-t.done:
+			return
+		case data := <-t.queue:
+			batch = append(batch, data)
+			if len(batch) >= t.cfg.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-t.flushch:
+			drain()
+			close(ack)
+		}
+	}
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+// SetDefault sets the Tracer used by the package level Start function.
+func SetDefault(t *Tracer) { defaultTracer.Store(t) }
+
+// Default returns the Tracer set by SetDefault. Without one, a Tracer without
+// exporter is returned so spans are still created and propagated.
+func Default() *Tracer {
+	if t := defaultTracer.Load(); t != nil {
+		return t
+	}
+	return noopTracer
+}
+
+var noopTracer = &Tracer{cfg: Config{}}
+
+// Start starts a span using the default Tracer.
+func Start(ctx context.Context, name string, opts ...StartOption) (context.Context, *Span) {
+	return Default().Start(ctx, name, opts...)
+}
@@ -0,0 +1,72 @@
+package trace
+
+import (
+	"context"
+	"database/sql"
+)
+
+// DB wraps a *sql.DB so that every query runs in a client span. It implements
+// the ConnPool interface expected by ORMs such as gorm, so it can be passed
+// wherever an existing *sql.DB connection is accepted.
+type DB struct {
+	*sql.DB
+	tracer *Tracer
+	system string
+}
+
+// WrapDB returns db wrapped in a tracing DB. System names the database, for
+// example "sqlite" or "postgresql", and is recorded on every span. A nil t
+// uses the default Tracer at query time, which allows wrapping the database
+// before the Tracer is configured.
+func WrapDB(t *Tracer, db *sql.DB, system string) *DB {
+	return &DB{DB: db, tracer: t, system: system}
+}
+
+func (db *DB) start(ctx context.Context, op, query string) (context.Context, *Span) {
+	t := db.tracer
+	if t == nil {
+		t = Default()
+	}
+	return t.Start(ctx, op, WithKind(KindClient), WithAttributes(map[string]any{
+		"db.system":    db.system,
+		"db.statement": query,
+	}))
+}
+
+// ExecContext executes query in a span.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := db.start(ctx, "db.exec", query)
+	defer span.End()
+	res, err := db.DB.ExecContext(ctx, query, args...)
+	span.RecordError(err)
+	return res, err
+}
+
+// QueryContext executes query in a span. The span ends once the rows were
+// returned, not when they are closed.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := db.start(ctx, "db.query", query)
+	defer span.End()
+	rows, err := db.DB.QueryContext(ctx, query, args...)
+	span.RecordError(err)
+	return rows, err
+}
+
+// QueryRowContext executes query in a span.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := db.start(ctx, "db.query", query)
+	defer span.End()
+	row := db.DB.QueryRowContext(ctx, query, args...)
+	span.RecordError(row.Err())
+	return row
+}
+
+// PrepareContext prepares query in a span. Executions of the returned
+// statement are not traced.
+func (db *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := db.start(ctx, "db.prepare", query)
+	defer span.End()
+	stmt, err := db.DB.PrepareContext(ctx, query)
+	span.RecordError(err)
+	return stmt, err
+}
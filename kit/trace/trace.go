@@ -0,0 +1,207 @@
+// Package trace implements lightweight distributed tracing with W3C Trace
+// Context (traceparent/tracestate) propagation.
+//
+// A Tracer creates spans and hands finished spans to an Exporter. Spans are
+// stored in the context so that HTTP handlers, event handlers and SQL queries
+// started from the same context end up in the same trace.
+//
+//	exp, _ := trace.NewFileExporter("traces.jsonl")
+//	tracer := trace.NewTracer(trace.Config{ServiceName: "app", Exporter: exp})
+//	trace.SetDefault(tracer)
+//	router.Use(trace.Middleware(tracer, trace.MiddlewareConfig{}))
+package trace
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// String returns the lowercase hex encoding of the id.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the id is not all zeros.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// String returns the lowercase hex encoding of the id.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the id is not all zeros.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// FlagSampled is the trace flag marking a trace as sampled.
+const FlagSampled byte = 0x01
+
+// SpanContext is the part of a span that is propagated across process
+// boundaries.
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Flags      byte
+	TraceState string
+	// Remote is true if the span context was extracted from an incoming request.
+	Remote bool
+}
+
+// IsValid reports whether both the trace and span id are set.
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// IsSampled reports whether the sampled flag is set.
+func (sc SpanContext) IsSampled() bool { return sc.Flags&FlagSampled != 0 }
+
+// Traceparent returns the span context encoded as a version 00 traceparent header.
+func (sc SpanContext) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags)
+}
+
+// ErrInvalidTraceparent is returned when a traceparent header cannot be parsed.
+var ErrInvalidTraceparent = errors.New("trace: invalid traceparent")
+
+// ParseTraceparent parses a W3C traceparent header value. Versions above 00
+// are accepted as long as their prefix has the version 00 layout.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+	s = strings.TrimSpace(s)
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+	version, ok := decodeHex(s[0:2])
+	if !ok || version[0] == 0xff {
+		return sc, ErrInvalidTraceparent
+	}
+	if version[0] == 0 && len(s) != 55 {
+		return sc, ErrInvalidTraceparent
+	}
+	if len(s) > 55 && s[55] != '-' {
+		return sc, ErrInvalidTraceparent
+	}
+	traceID, ok := decodeHex(s[3:35])
+	if !ok {
+		return sc, ErrInvalidTraceparent
+	}
+	spanID, ok := decodeHex(s[36:52])
+	if !ok {
+		return sc, ErrInvalidTraceparent
+	}
+	flags, ok := decodeHex(s[53:55])
+	if !ok {
+		return sc, ErrInvalidTraceparent
+	}
+	copy(sc.TraceID[:], traceID)
+	copy(sc.SpanID[:], spanID)
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// decodeHex decodes lowercase hex only, as required by the specification.
+func decodeHex(s string) ([]byte, bool) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return nil, false
+		}
+	}
+	b, err := hex.DecodeString(s)
+	return b, err == nil
+}
+
+// maxTraceStateMembers is the maximum number of list members allowed in a
+// tracestate header.
+const maxTraceStateMembers = 32
+
+// ParseTraceState validates a tracestate header value and returns it in
+// normalized form. Malformed and duplicate members are dropped, and the list
+// is truncated to 32 members.
+func ParseTraceState(s string) string {
+	var (
+		members []string
+		seen    = make(map[string]bool)
+	)
+	for _, m := range strings.Split(s, ",") {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(m, "=")
+		if !ok || key == "" || value == "" || len(key) > 256 || len(value) > 256 || seen[key] {
+			continue
+		}
+		if strings.ContainsAny(key, " \t") || strings.ContainsAny(value, ",=") {
+			continue
+		}
+		seen[key] = true
+		members = append(members, key+"="+value)
+		if len(members) == maxTraceStateMembers {
+			break
+		}
+	}
+	return strings.Join(members, ",")
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		putUint64(id[:8], rand.Uint64())
+		putUint64(id[8:], rand.Uint64())
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		putUint64(id[:], rand.Uint64())
+	}
+	return id
+}
+
+func putUint64(b []byte, v uint64) {
+	for i := 0; i < 8; i++ {
+		b[i] = byte(v >> (56 - 8*i))
+	}
+}
+
+type spanKey struct{}
+
+type remoteKey struct{}
+
+// ContextWithSpan returns a copy of ctx holding span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span stored in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpanContext returns a copy of ctx holding a span context
+// extracted from a remote caller. Spans started from the returned context
+// become its children.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context of the span in ctx, falling
+// back to a remote span context stored by ContextWithRemoteSpanContext.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.SpanContext()
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
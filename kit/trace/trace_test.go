@@ -0,0 +1,133 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	valid := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.IsSampled() {
+		t.Errorf("unexpected span context %+v", sc)
+	}
+	if sc.Traceparent() != valid {
+		t.Errorf("expected %s got %s", valid, sc.Traceparent())
+	}
+
+	for _, s := range []string{
+		"",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := ParseTraceparent(s); err == nil {
+			t.Errorf("expected %q to be invalid", s)
+		}
+	}
+	if _, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"); err != nil {
+		t.Errorf("expected future version to be accepted: %v", err)
+	}
+}
+
+func TestParseTraceState(t *testing.T) {
+	got := ParseTraceState("rojo=00f067aa0ba902b7, congo=t61rcWkgMzE,bad, rojo=dup")
+	if got != "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE" {
+		t.Errorf("unexpected tracestate %q", got)
+	}
+}
+
+func TestMiddlewarePropagation(t *testing.T) {
+	var buf bytes.Buffer
+	tracer := NewTracer(Config{ServiceName: "test", Exporter: NewJSONLinesExporter(&buf)})
+
+	var child SpanContext
+	h := Middleware(tracer, MiddlewareConfig{})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracer.Start(r.Context(), "db.query")
+		child = span.SpanContext()
+		span.End()
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("tracestate", "rojo=00f067aa0ba902b7")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if child.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || child.TraceState != "rojo=00f067aa0ba902b7" {
+		t.Errorf("expected child to continue the incoming trace, got %+v", child)
+	}
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var spans []SpanData
+	dec := json.NewDecoder(&buf)
+	for {
+		var s SpanData
+		if err := dec.Decode(&s); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		spans = append(spans, s)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans got %d", len(spans))
+	}
+	server := spans[1]
+	if server.Kind != KindServer || server.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("unexpected server span %+v", server)
+	}
+	if spans[0].ParentSpanID != server.SpanID {
+		t.Errorf("expected db span to be a child of the server span")
+	}
+	if !strings.HasPrefix(rec.Header().Get("traceparent"), "00-4bf92f3577b34da6a3ce929d0e0e4736-"+server.SpanID) {
+		t.Errorf("unexpected response traceparent %q", rec.Header().Get("traceparent"))
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	var got otlpExportRequest
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("X-Api-Key") != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer collector.Close()
+
+	exp := NewOTLPExporter(OTLPConfig{
+		Endpoint: collector.URL,
+		Headers:  map[string]string{"X-Api-Key": "secret"},
+	})
+	tracer := NewTracer(Config{ServiceName: "app", Exporter: exp})
+	_, span := tracer.Start(context.Background(), "auth.signup", WithKind(KindProducer))
+	span.SetAttribute("user.id", 1)
+	span.End()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans[0].Spans) != 1 {
+		t.Fatalf("unexpected export %+v", got)
+	}
+	s := got.ResourceSpans[0].ScopeSpans[0].Spans[0]
+	if s.Name != "auth.signup" || s.Kind != int(KindProducer) || s.TraceID != span.SpanContext().TraceID.String() {
+		t.Errorf("unexpected span %+v", s)
+	}
+	if attr := got.ResourceSpans[0].Resource.Attributes[0]; attr.Key != "service.name" || attr.Value["stringValue"] != "app" {
+		t.Errorf("unexpected resource %+v", got.ResourceSpans[0].Resource)
+	}
+}
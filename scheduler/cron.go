@@ -0,0 +1,300 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes the run times of a task.
+type Schedule interface {
+	// Next returns the first run time after t, or the zero time if there is
+	// none.
+	Next(t time.Time) time.Time
+}
+
+// Parse parses a schedule:
+//
+//	"*/5 * * * *"            standard cron: minute hour day-of-month month day-of-week
+//	"30 */5 * * * *"         with a leading seconds field
+//	"0 9 * * MON-FRI"        month and weekday names
+//	"CRON_TZ=Europe/Berlin 0 9 * * *"  in a time zone
+//	"@every 90s"             at a fixed interval
+//	"@daily"                 @yearly, @monthly, @weekly, @daily and @hourly
+//
+// Fields accept *, ?, values, ranges (1-5), lists (1,15) and steps (*/10,
+// 0-30/5). As in cron, when both day fields are restricted a day matches
+// either of them. Cron schedules without a time zone run in the location of
+// the time passed to Next.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	var loc *time.Location
+	if strings.HasPrefix(spec, "CRON_TZ=") || strings.HasPrefix(spec, "TZ=") {
+		zone, rest, _ := strings.Cut(spec, " ")
+		_, name, _ := strings.Cut(zone, "=")
+		var err error
+		loc, err = time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("scheduler: invalid time zone %q: %w", name, err)
+		}
+		spec = strings.TrimSpace(rest)
+	}
+
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("scheduler: invalid interval in %q: %w", spec, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("scheduler: interval must be positive in %q", spec)
+		}
+		return every(d), nil
+	}
+	if expr, ok := descriptors[spec]; ok {
+		spec = expr
+	} else if strings.HasPrefix(spec, "@") {
+		return nil, fmt.Errorf("scheduler: unknown descriptor %q", spec)
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("scheduler: expected 5 or 6 fields in %q, got %d", spec, len(fields))
+	}
+	s := &cron{loc: loc}
+	var err error
+	for i, dst := range []*uint64{&s.second, &s.minute, &s.hour, &s.dom, &s.month, &s.dow} {
+		if *dst, err = parseField(fields[i], bounds[i]); err != nil {
+			return nil, fmt.Errorf("scheduler: %s field of %q: %w", bounds[i].name, spec, err)
+		}
+	}
+	// 7 is another name for Sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	s.domStar = isStar(fields[3])
+	s.dowStar = isStar(fields[5])
+	return s, nil
+}
+
+// MustParse is like Parse but panics if spec is invalid.
+func MustParse(spec string) Schedule {
+	s, err := Parse(spec)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+type bound struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var bounds = []bound{
+	{name: "second", min: 0, max: 59},
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day-of-month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	{name: "day-of-week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+func isStar(field string) bool {
+	return field == "*" || field == "?"
+}
+
+// parseField returns the bit set of the values matched by field.
+func parseField(field string, b bound) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+		var lo, hi int
+		switch {
+		case isStar(rng):
+			lo, hi = b.min, b.max
+			if b.name == "day-of-week" {
+				hi = 6
+			}
+		case strings.Contains(rng, "-"):
+			loStr, hiStr, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = parseValue(loStr, b); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(hiStr, b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q", rng)
+			}
+		default:
+			var err error
+			if lo, err = parseValue(rng, b); err != nil {
+				return 0, err
+			}
+			hi = lo
+			// a/n means from a to the end in steps of n
+			if hasStep {
+				hi = b.max
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, b bound) (int, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < b.min || v > b.max {
+		return 0, fmt.Errorf("value %d out of range [%d, %d]", v, b.min, b.max)
+	}
+	return v, nil
+}
+
+// cron is a parsed cron expression. Each field is a bit set of the matching
+// values.
+type cron struct {
+	second, minute, hour, dom, month, dow uint64
+	// domStar and dowStar record unrestricted day fields, which changes how
+	// the two are combined
+	domStar, dowStar bool
+	// loc overrides the location of the times passed to Next
+	loc *time.Location
+}
+
+// Next returns the first matching time after t, searching up to five years
+// ahead. It walks the fields from the month down to the second, resetting
+// the smaller fields whenever a larger one advances.
+func (c *cron) Next(t time.Time) time.Time {
+	orig := t.Location()
+	if c.loc != nil {
+		t = t.In(c.loc)
+	}
+	loc := t.Location()
+
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	limit := t.Year() + 5
+	// added records whether a field advanced, after which the smaller fields
+	// start from their minimum
+	added := false
+
+wrap:
+	if t.Year() > limit {
+		return time.Time{}
+	}
+	for c.month&(1<<uint(t.Month())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !c.dayMatches(t) {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		// midnight may not exist on days daylight saving time starts
+		if t.Hour() != 0 {
+			if t.Hour() > 12 {
+				t = t.Add(time.Duration(24-t.Hour()) * time.Hour)
+			} else {
+				t = t.Add(time.Duration(-t.Hour()) * time.Hour)
+			}
+		}
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+	for c.hour&(1<<uint(t.Hour())) == 0 {
+		if !added {
+			added = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+	for c.minute&(1<<uint(t.Minute())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Minute)
+		}
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+	for c.second&(1<<uint(t.Second())) == 0 {
+		if !added {
+			added = true
+			t = t.Truncate(time.Second)
+		}
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto wrap
+		}
+	}
+	return t.In(orig)
+}
+
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// every runs at a fixed interval. Run times are multiples of the interval
+// since the zero time rather than offsets from when the scheduler started,
+// so instances started at different times agree on them.
+type every time.Duration
+
+func (e every) Next(t time.Time) time.Time {
+	d := time.Duration(e)
+	return t.Truncate(d).Add(d)
+}
@@ -0,0 +1,87 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	from := time.Date(2026, 10, 18, 10, 7, 30, 0, time.UTC) // a Sunday
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"*/5 * * * *", time.Date(2026, 10, 18, 10, 10, 0, 0, time.UTC)},
+		{"30 */5 * * * *", time.Date(2026, 10, 18, 10, 10, 30, 0, time.UTC)},
+		{"0 9 * * MON-FRI", time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1,15 * *", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * 7", time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)},
+		// both day fields restricted: either matches
+		{"0 0 13 * FRI", time.Date(2026, 10, 23, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2026, 10, 18, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 15m", time.Date(2026, 10, 18, 10, 15, 0, 0, time.UTC)},
+		{"CRON_TZ=Europe/Berlin 0 9 * * *", time.Date(2026, 10, 19, 9, 0, 0, 0, berlin)},
+		// the clocks go back on 25 October 2026 in Berlin
+		{"TZ=Europe/Berlin 0 3 25 10 *", time.Date(2026, 10, 25, 2, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Errorf("%s: %v", tt.spec, err)
+			continue
+		}
+		if got := s.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%s: expected %v got %v", tt.spec, tt.want, got)
+		}
+	}
+}
+
+func TestParseLocalTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	s := MustParse("0 9 * * *")
+	from := time.Date(2026, 10, 18, 10, 0, 0, 0, berlin)
+	if got, want := s.Next(from), time.Date(2026, 10, 19, 9, 0, 0, 0, berlin); !got.Equal(want) {
+		t.Fatalf("expected %v got %v", want, got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"* * * *",
+		"* * * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * FOO *",
+		"@fortnightly",
+		"@every -1s",
+		"@every soon",
+		"CRON_TZ=Nowhere/Land * * * * *",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
+
+func TestNextUnsatisfiable(t *testing.T) {
+	s := MustParse("0 0 30 2 *")
+	if got := s.Next(time.Now()); !got.IsZero() {
+		t.Fatalf("expected no run time got %v", got)
+	}
+}
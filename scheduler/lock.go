@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/khulnasoft/superkit/db"
+)
+
+// Locker coordinates the schedulers of several instances of an application
+// so a run of a task happens on one of them only.
+type Locker interface {
+	// Acquire claims a run of a task for this instance. It reports false if
+	// another instance already claimed the run or, for exclusive leases,
+	// still holds a lease of an earlier run.
+	Acquire(ctx context.Context, l Lease) (bool, error)
+	// Extend renews the lease of a task held by this instance.
+	Extend(ctx context.Context, task string, until time.Time) error
+	// Release drops the lease of a task held by this instance.
+	Release(ctx context.Context, task string) error
+}
+
+// Lease is a claim of a run of a task.
+type Lease struct {
+	Task string
+	// Run is the scheduled time of the run.
+	Run time.Time
+	// Until is when the lease expires unless extended, so the runs of an
+	// instance that died do not block the task forever.
+	Until time.Time
+	// Exclusive fails the claim while another run of the task holds a lease.
+	Exclusive bool
+}
+
+// LockTable is the name of the table SQLLocker stores leases in.
+const LockTable = "scheduler_locks"
+
+// SQLiteSchema creates the lock table for sqlite.
+const SQLiteSchema = `create table if not exists scheduler_locks(
+	task text primary key,
+	run_at datetime not null,
+	locked_by text,
+	locked_until datetime not null
+);`
+
+// PostgresSchema creates the lock table for postgres.
+const PostgresSchema = `create table if not exists scheduler_locks(
+	task text primary key,
+	run_at timestamptz not null,
+	locked_by text,
+	locked_until timestamptz not null
+);`
+
+// MySQLSchema creates the lock table for mysql.
+const MySQLSchema = `create table if not exists scheduler_locks(
+	task varchar(191) primary key,
+	run_at datetime(6) not null,
+	locked_by varchar(191),
+	locked_until datetime(6) not null
+);`
+
+// Execer runs statements. *sql.DB implements it.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// SQLLocker is a Locker storing leases in a table shared by all instances,
+// see SQLiteSchema, PostgresSchema and MySQLSchema. A run is claimed with a
+// conditional update, which the database applies atomically, so it works
+// the same on every database without row locks.
+type SQLLocker struct {
+	db     Execer
+	driver string
+	owner  string
+}
+
+// NewSQLLocker returns a Locker using conn, whose database/sql driver is
+// driver ("sqlite3", "postgres", "pgx" or "mysql").
+func NewSQLLocker(conn Execer, driver string) *SQLLocker {
+	return &SQLLocker{
+		db:     conn,
+		driver: driver,
+		owner:  db.OwnerID(),
+	}
+}
+
+// Acquire implements Locker.
+func (l *SQLLocker) Acquire(ctx context.Context, lease Lease) (bool, error) {
+	now := time.Now().UTC()
+	// Make sure the task has a row to update.
+	insert := "insert into " + LockTable + " (task, run_at, locked_until) values (?, ?, ?)"
+	if l.driver == "mysql" {
+		insert = strings.Replace(insert, "insert", "insert ignore", 1)
+	} else {
+		insert += " on conflict (task) do nothing"
+	}
+	epoch := time.Unix(0, 0).UTC()
+	if _, err := l.db.ExecContext(ctx, l.rebind(insert), lease.Task, epoch, epoch); err != nil {
+		return false, err
+	}
+
+	query := "update " + LockTable + " set run_at = ?, locked_by = ?, locked_until = ? where task = ? and run_at < ?"
+	args := []any{lease.Run.UTC(), l.owner, lease.Until.UTC(), lease.Task, lease.Run.UTC()}
+	if lease.Exclusive {
+		query += " and locked_until <= ?"
+		args = append(args, now)
+	}
+	res, err := l.db.ExecContext(ctx, l.rebind(query), args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// Extend implements Locker.
+func (l *SQLLocker) Extend(ctx context.Context, task string, until time.Time) error {
+	_, err := l.db.ExecContext(ctx, l.rebind(
+		"update "+LockTable+" set locked_until = ? where task = ? and locked_by = ?"),
+		until.UTC(), task, l.owner)
+	return err
+}
+
+// Release implements Locker.
+func (l *SQLLocker) Release(ctx context.Context, task string) error {
+	_, err := l.db.ExecContext(ctx, l.rebind(
+		"update "+LockTable+" set locked_until = ? where task = ? and locked_by = ?"),
+		time.Now().UTC(), task, l.owner)
+	return err
+}
+
+// rebind rewrites the ? placeholders of query for the locker's driver.
+func (l *SQLLocker) rebind(query string) string {
+	return db.Rebind(l.driver, query)
+}
@@ -0,0 +1,324 @@
+// Package scheduler runs recurring tasks on cron schedules.
+//
+//	s := scheduler.New(scheduler.Config{
+//		Locker: scheduler.NewSQLLocker(sqlDB, "sqlite3"),
+//	})
+//	s.Add("purge-sessions", "@hourly", purgeExpiredSessions, scheduler.TaskOptions{})
+//	s.Add("digest", "CRON_TZ=Europe/Berlin 0 8 * * MON-FRI", sendDigest, scheduler.TaskOptions{})
+//
+// Scheduler.Run runs the tasks until its context is cancelled, which makes
+// it fit for kit.ServeConfig.Background. With a Locker, every run happens on
+// one instance only when the application runs on several.
+//
+// Each run emits TaskStarted, then TaskFinished or TaskFailed.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/khulnasoft/superkit/event"
+)
+
+// TaskRun describes a run of a task in the scheduler events.
+type TaskRun struct {
+	Task string
+	// ScheduledAt is the time the run was due.
+	ScheduledAt time.Time
+	StartedAt   time.Time
+	// Duration is set once the run finished.
+	Duration time.Duration
+	// Error is the error of a failed run.
+	Error string
+}
+
+var (
+	// TaskStarted is emitted when a run starts.
+	TaskStarted = event.NewTopic[TaskRun]("scheduler.task.started")
+	// TaskFinished is emitted when a run succeeds.
+	TaskFinished = event.NewTopic[TaskRun]("scheduler.task.finished")
+	// TaskFailed is emitted when a run returns an error or panics.
+	TaskFailed = event.NewTopic[TaskRun]("scheduler.task.failed")
+)
+
+// Config configures a Scheduler.
+type Config struct {
+	// Location is the time zone of cron schedules without CRON_TZ.
+	// Defaults to time.Local.
+	Location *time.Location
+	// Locker makes sure a run happens on one instance only. Without it,
+	// every instance runs every task.
+	Locker Locker
+	// LockTTL is how long a lease of a run lasts. Running tasks renew their
+	// lease; the lease of an instance that died expires after LockTTL.
+	// Defaults to one minute.
+	LockTTL time.Duration
+	// Bus receives the task events. Defaults to the default bus.
+	Bus *event.Bus
+	// ShutdownTimeout is how long Run waits for running tasks once its
+	// context is cancelled before cancelling theirs. Defaults to 30 seconds.
+	ShutdownTimeout time.Duration
+}
+
+// TaskOptions control how a task runs.
+type TaskOptions struct {
+	// Location overrides Config.Location for the task.
+	Location *time.Location
+	// AllowOverlap starts a run even if the previous one has not finished.
+	// By default such runs are skipped.
+	AllowOverlap bool
+	// Timeout cancels the context of a run after the given duration.
+	Timeout time.Duration
+}
+
+// Scheduler runs tasks on their schedules.
+type Scheduler struct {
+	cfg Config
+
+	mu    sync.Mutex
+	tasks map[string]*task
+	// wakes Run when a task was added
+	wake chan struct{}
+}
+
+type task struct {
+	name     string
+	schedule Schedule
+	fn       func(ctx context.Context) error
+	opts     TaskOptions
+	next     time.Time
+	running  atomic.Int32
+}
+
+// New returns a Scheduler.
+func New(cfg Config) *Scheduler {
+	if cfg.Location == nil {
+		cfg.Location = time.Local
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = time.Minute
+	}
+	if cfg.ShutdownTimeout <= 0 {
+		cfg.ShutdownTimeout = 30 * time.Second
+	}
+	return &Scheduler{
+		cfg:   cfg,
+		tasks: make(map[string]*task),
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Add schedules fn to run at the times of spec, see Parse. name identifies
+// the task in logs, events and locks, so it must be unique and stay the
+// same across deploys.
+func (s *Scheduler) Add(name, spec string, fn func(ctx context.Context) error, opts TaskOptions) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+	return s.AddSchedule(name, schedule, fn, opts)
+}
+
+// AddSchedule is like Add with a parsed schedule.
+func (s *Scheduler) AddSchedule(name string, schedule Schedule, fn func(ctx context.Context) error, opts TaskOptions) error {
+	if opts.Location == nil {
+		opts.Location = s.cfg.Location
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[name]; ok {
+		return fmt.Errorf("scheduler: task %s already exists", name)
+	}
+	t := &task{name: name, schedule: schedule, fn: fn, opts: opts}
+	t.next = schedule.Next(time.Now().In(opts.Location))
+	s.tasks[name] = t
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Next returns the next run time of a task, or the zero time if the task
+// does not exist or will not run again.
+func (s *Scheduler) Next(name string) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.tasks[name]; ok {
+		return t.next
+	}
+	return time.Time{}
+}
+
+// Run runs the tasks on their schedules until ctx is cancelled. Runs missed
+// while the scheduler was not running are not caught up on.
+//
+// Once ctx is cancelled Run waits up to Config.ShutdownTimeout for running
+// tasks, then cancels their context and waits for them to return.
+func (s *Scheduler) Run(ctx context.Context) error {
+	// Tasks keep running after ctx is cancelled until the shutdown timeout.
+	runCtx, cancelRuns := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelRuns()
+	var wg sync.WaitGroup
+
+loop:
+	for {
+		now := time.Now()
+		var next time.Time
+		s.mu.Lock()
+		for name, t := range s.tasks {
+			if t.next.IsZero() {
+				// the schedule has no more run times
+				delete(s.tasks, name)
+				continue
+			}
+			if !t.next.After(now) {
+				wg.Add(1)
+				go func(at time.Time) {
+					defer wg.Done()
+					s.run(runCtx, t, at)
+				}(t.next)
+				t.next = t.schedule.Next(now.In(t.opts.Location))
+				if t.next.IsZero() {
+					continue
+				}
+			}
+			if next.IsZero() || t.next.Before(next) {
+				next = t.next
+			}
+		}
+		s.mu.Unlock()
+
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			break loop
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(s.cfg.ShutdownTimeout):
+		slog.Warn("scheduler: cancelling running tasks after shutdown timeout")
+		cancelRuns()
+		<-done
+	}
+	return nil
+}
+
+// run runs t for the run due at at, unless the run overlaps a running one
+// or another instance claimed it.
+func (s *Scheduler) run(ctx context.Context, t *task, at time.Time) {
+	if n := t.running.Add(1); n > 1 && !t.opts.AllowOverlap {
+		t.running.Add(-1)
+		slog.Warn("scheduler: skipping run, the previous run has not finished", "task", t.name, "scheduled_at", at)
+		return
+	}
+	defer t.running.Add(-1)
+
+	if l := s.cfg.Locker; l != nil {
+		ok, err := l.Acquire(ctx, Lease{
+			Task:      t.name,
+			Run:       at,
+			Until:     time.Now().Add(s.cfg.LockTTL),
+			Exclusive: !t.opts.AllowOverlap,
+		})
+		if err != nil {
+			slog.Error("scheduler: acquiring lock failed", "task", t.name, "err", err)
+			return
+		}
+		if !ok {
+			slog.Debug("scheduler: run claimed by another instance", "task", t.name, "scheduled_at", at)
+			return
+		}
+		stop := s.keepLease(t.name)
+		defer func() {
+			stop()
+			if err := l.Release(context.WithoutCancel(ctx), t.name); err != nil {
+				slog.Error("scheduler: releasing lock failed", "task", t.name, "err", err)
+			}
+		}()
+	}
+
+	if t.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, t.opts.Timeout)
+		defer cancel()
+	}
+	info := TaskRun{Task: t.name, ScheduledAt: at, StartedAt: time.Now()}
+	s.emit(ctx, TaskStarted, info)
+	err := call(ctx, t)
+	info.Duration = time.Since(info.StartedAt)
+	if err != nil {
+		info.Error = err.Error()
+		slog.Error("scheduler: task failed", "task", t.name, "scheduled_at", at, "duration", info.Duration, "err", err)
+		s.emit(ctx, TaskFailed, info)
+		return
+	}
+	slog.Debug("scheduler: task done", "task", t.name, "duration", info.Duration)
+	s.emit(ctx, TaskFinished, info)
+}
+
+// keepLease renews the lease of a running task until the returned function
+// is called.
+func (s *Scheduler) keepLease(name string) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(s.cfg.LockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			if err := s.cfg.Locker.Extend(context.Background(), name, time.Now().Add(s.cfg.LockTTL)); err != nil {
+				slog.Error("scheduler: renewing lock failed", "task", name, "err", err)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// call runs the task, turning a panic into an error.
+func call(ctx context.Context, t *task) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("scheduler: task panicked", "task", t.name, "stack", string(debug.Stack()))
+			err = fmt.Errorf("scheduler: %s panicked: %v", t.name, r)
+		}
+	}()
+	return t.fn(ctx)
+}
+
+func (s *Scheduler) emit(ctx context.Context, topic event.Topic[TaskRun], run TaskRun) {
+	// Events about the run must not be cut short by its timeout.
+	ctx = context.WithoutCancel(ctx)
+	var err error
+	if s.cfg.Bus != nil {
+		err = s.cfg.Bus.EmitContext(ctx, topic.Name(), run)
+	} else {
+		err = topic.Emit(ctx, run)
+	}
+	if err != nil {
+		slog.Warn("scheduler: emitting event failed", "topic", topic.Name(), "err", err)
+	}
+}
@@ -0,0 +1,255 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/khulnasoft/superkit/event"
+)
+
+func TestSchedulerEvents(t *testing.T) {
+	bus := event.New(event.Config{})
+	defer bus.Stop()
+	runs := make(chan TaskRun, 10)
+	bus.Subscribe("scheduler.task.*", func(_ context.Context, msg any) {
+		runs <- msg.(TaskRun)
+	})
+
+	s := New(Config{Bus: bus})
+	boom := errors.New("boom")
+	var calls atomic.Int32
+	err := s.AddSchedule("flaky", every(20*time.Millisecond), func(context.Context) error {
+		if calls.Add(1) == 1 {
+			return boom
+		}
+		return nil
+	}, TaskOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add("flaky", "@daily", nil, TaskOptions{}); err == nil {
+		t.Fatal("expected duplicate task error")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	var started, finished, failed int
+	// events of a run may arrive out of order
+	for finished == 0 || started < 2 {
+		select {
+		case run := <-runs:
+			switch {
+			case run.Error != "":
+				failed++
+				if run.Error != "boom" {
+					t.Errorf("expected boom got %q", run.Error)
+				}
+			case run.Duration > 0:
+				finished++
+			default:
+				started++
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for runs")
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if started < 2 || failed != 1 {
+		t.Fatalf("expected 2 starts and 1 failure, got %d and %d", started, failed)
+	}
+}
+
+func TestSchedulerSkipsOverlap(t *testing.T) {
+	bus := event.New(event.Config{})
+	defer bus.Stop()
+	for _, allow := range []bool{false, true} {
+		s := New(Config{Bus: bus})
+		var running, maxRunning atomic.Int32
+		s.AddSchedule("slow", every(10*time.Millisecond), func(context.Context) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			return nil
+		}, TaskOptions{AllowOverlap: allow})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+		s.Run(ctx)
+		cancel()
+		if got := maxRunning.Load(); allow != (got > 1) {
+			t.Fatalf("allow overlap %v: got %d concurrent runs", allow, got)
+		}
+	}
+}
+
+func TestSchedulerShutdownCancelsRuns(t *testing.T) {
+	bus := event.New(event.Config{})
+	defer bus.Stop()
+	s := New(Config{Bus: bus, ShutdownTimeout: 20 * time.Millisecond})
+	started := make(chan struct{}, 1)
+	s.AddSchedule("stuck", every(10*time.Millisecond), func(ctx context.Context) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}, TaskOptions{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+	<-started
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after the shutdown timeout")
+	}
+}
+
+// memoryLocker is a Locker shared by the schedulers of a test.
+type memoryLocker struct {
+	mu    sync.Mutex
+	runs  map[string]time.Time
+	until map[string]time.Time
+}
+
+func (l *memoryLocker) Acquire(_ context.Context, lease Lease) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.runs[lease.Task].Before(lease.Run) {
+		return false, nil
+	}
+	if lease.Exclusive && l.until[lease.Task].After(time.Now()) {
+		return false, nil
+	}
+	l.runs[lease.Task] = lease.Run
+	l.until[lease.Task] = lease.Until
+	return true, nil
+}
+
+func (l *memoryLocker) Extend(_ context.Context, task string, until time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.until[task] = until
+	return nil
+}
+
+func (l *memoryLocker) Release(_ context.Context, task string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.until, task)
+	return nil
+}
+
+func TestSchedulerLocker(t *testing.T) {
+	bus := event.New(event.Config{})
+	defer bus.Stop()
+	locker := &memoryLocker{runs: make(map[string]time.Time), until: make(map[string]time.Time)}
+
+	var mu sync.Mutex
+	seen := make(map[time.Time]int)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	// three instances of the application
+	for range 3 {
+		s := New(Config{Bus: bus, Locker: locker})
+		s.AddSchedule("purge", every(20*time.Millisecond), func(context.Context) error {
+			mu.Lock()
+			seen[time.Now().Truncate(20*time.Millisecond)]++
+			mu.Unlock()
+			return nil
+		}, TaskOptions{})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	if len(seen) < 3 {
+		t.Fatalf("expected several runs, got %d", len(seen))
+	}
+	for at, n := range seen {
+		if n != 1 {
+			t.Fatalf("run at %v happened %d times", at, n)
+		}
+	}
+}
+
+type recordingExecer struct {
+	queries []string
+	args    [][]any
+}
+
+func (e *recordingExecer) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	e.queries = append(e.queries, query)
+	e.args = append(e.args, args)
+	return affected(1), nil
+}
+
+type affected int64
+
+func (a affected) LastInsertId() (int64, error) { return 0, nil }
+func (a affected) RowsAffected() (int64, error) { return int64(a), nil }
+
+func TestSQLLocker(t *testing.T) {
+	run := time.Date(2026, 10, 18, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		driver string
+		insert string
+		update string
+	}{
+		{"sqlite3",
+			"insert into scheduler_locks (task, run_at, locked_until) values (?, ?, ?) on conflict (task) do nothing",
+			"update scheduler_locks set run_at = ?, locked_by = ?, locked_until = ? where task = ? and run_at < ? and locked_until <= ?"},
+		{"postgres",
+			"insert into scheduler_locks (task, run_at, locked_until) values ($1, $2, $3) on conflict (task) do nothing",
+			"update scheduler_locks set run_at = $1, locked_by = $2, locked_until = $3 where task = $4 and run_at < $5 and locked_until <= $6"},
+		{"mysql",
+			"insert ignore into scheduler_locks (task, run_at, locked_until) values (?, ?, ?)",
+			"update scheduler_locks set run_at = ?, locked_by = ?, locked_until = ? where task = ? and run_at < ? and locked_until <= ?"},
+	}
+	for _, tt := range tests {
+		db := &recordingExecer{}
+		l := NewSQLLocker(db, tt.driver)
+		ok, err := l.Acquire(context.Background(), Lease{Task: "purge", Run: run, Until: run.Add(time.Minute), Exclusive: true})
+		if err != nil || !ok {
+			t.Fatalf("%s: expected lease, got %v %v", tt.driver, ok, err)
+		}
+		if db.queries[0] != tt.insert {
+			t.Errorf("%s: unexpected insert %q", tt.driver, db.queries[0])
+		}
+		if db.queries[1] != tt.update {
+			t.Errorf("%s: unexpected update %q", tt.driver, db.queries[1])
+		}
+		if db.args[1][0] != run || db.args[1][4] != run {
+			t.Errorf("%s: expected the run time to be claimed, got %v", tt.driver, db.args[1])
+		}
+		if err := l.Release(context.Background(), "purge"); err != nil {
+			t.Fatal(err)
+		}
+		if last := db.queries[2]; !strings.Contains(last, "locked_by = ") {
+			t.Errorf("%s: expected release to check the owner, got %q", tt.driver, last)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package validate
 
 import (
+	"errors"
 	"fmt"
 	"maps"
 	"net/http"
@@ -36,6 +37,16 @@ func (e Errors) Has(field string) bool {
 	return len(e[field]) > 0
 }
 
+// tooLargeKey is the key Request uses to report a body that exceeded the
+// limit of an http.MaxBytesReader.
+const tooLargeKey = "_tooLarge"
+
+// TooLarge returns true if the request body exceeded its size limit. Handlers
+// should respond with http.StatusRequestEntityTooLarge in that case.
+func (e Errors) TooLarge() bool {
+	return e.Has(tooLargeKey)
+}
+
 // Schema represents a validation schema.
 type Schema map[string][]RuleSet
 
@@ -63,13 +74,19 @@ func Validate(data any, fields Schema) (Errors, bool) {
 }
 
 // Request parses an http.Request into data and validates it based
-// on the given schema.
+// on the given schema. If the body exceeds the limit set by an
+// http.MaxBytesReader, validation is skipped and Errors.TooLarge reports true.
 func Request(r *http.Request, data any, schema Schema) (Errors, bool) {
-	errors := Errors{}
+	errs := Errors{}
 	if err := parseRequest(r, data); err != nil {
-		errors["_error"] = []string{err.Error()}
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			errs.Add(tooLargeKey, fmt.Sprintf("request body exceeds %d bytes", maxErr.Limit))
+			return errs, false
+		}
+		errs["_error"] = []string{err.Error()}
 	}
-	return validate(data, schema, errors)
+	return validate(data, schema, errs)
 }
 
 func validate(data any, schema Schema, errors Errors) (Errors, bool) {
@@ -122,7 +139,7 @@ func parseRequest(r *http.Request, v any) error {
 	contentType := r.Header.Get("Content-Type")
 	if contentType == "application/x-www-form-urlencoded" {
 		if err := r.ParseForm(); err != nil {
-			return fmt.Errorf("failed to parse form: %v", err)
+			return fmt.Errorf("failed to parse form: %w", err)
 		}
 		val := reflect.ValueOf(v).Elem()
 		for i := 0; i < val.NumField(); i++ {
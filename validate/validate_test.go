@@ -90,6 +90,24 @@ func TestValidateRequest(t *testing.T) {
 	assert.Equal(t, data.ARandomRenamedFloat, randomFloat)
 }
 
+func TestValidateRequestTooLarge(t *testing.T) {
+	formValues := url.Values{}
+	formValues.Set("email", "foo@bar.com")
+	req, err := http.NewRequest("POST", "http://foo.com", strings.NewReader(formValues.Encode()))
+	assert.Nil(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Body = http.MaxBytesReader(nil, req.Body, 4)
+
+	type Data struct {
+		Email string `form:"email"`
+	}
+	var data Data
+	errors, ok := Request(req, &data, Schema{"Email": Rules(Email)})
+	assert.False(t, ok)
+	assert.True(t, errors.TooLarge())
+	assert.False(t, errors.Has("email"))
+}
+
 func TestTime(t *testing.T) {
 	type Foo struct {
 		CreatedAt time.Time